@@ -1,11 +1,13 @@
 package scache
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -101,26 +103,42 @@ type Table[I IDType] interface {
 type DBCRUD[T Table[I], I IDType] interface {
 	//Creat create new record into dababase
 	Create(obj *T) error
+	CreateCtx(ctx context.Context, obj *T) error
 	//Save update if id exists or create new record
 	Save(obj *T) error
+	SaveCtx(ctx context.Context, obj *T) error
 	//Delete return (effectedrows,error)
 	Delete(ids ...I) (int64, error)
+	DeleteCtx(ctx context.Context, ids ...I) (int64, error)
 	// values can be struct or map[string]interface{}, return (effectedrows,error)
 	Update(id I, values interface{}) (int64, error)
+	UpdateCtx(ctx context.Context, id I, values interface{}) (int64, error)
 	//get obj by id
 	Get(id I) (T, error)
+	GetCtx(ctx context.Context, id I) (T, error)
 	//list objs by ids
 	List(ids ...I) ([]T, error)
+	ListCtx(ctx context.Context, ids ...I) ([]T, error)
 	//get obj by index
 	GetBy(index Index) (T, error)
+	GetByCtx(ctx context.Context, index Index) (T, error)
 	//list objs by indexes
 	ListBy(index Index, initOrders OrderBys) ([]T, error)
+	ListByCtx(ctx context.Context, index Index, initOrders OrderBys) ([]T, error)
+	//count objs by indexes
+	CountBy(index Index) (int64, error)
+	CountByCtx(ctx context.Context, index Index) (int64, error)
+	//list at most limit objs by indexes from offset
+	ListByPage(index Index, initOrders OrderBys, offset, limit int) ([]T, error)
+	ListByPageCtx(ctx context.Context, index Index, initOrders OrderBys, offset, limit int) ([]T, error)
 	//close lower clients
 	Close() error
 	//ListByUniqueInts list objs by unique index field in values
 	ListByUniqueInts(field string, values []int64) ([]T, error)
+	ListByUniqueIntsCtx(ctx context.Context, field string, values []int64) ([]T, error)
 	//ListByUniqueStrs list objs by unique index field in values
 	ListByUniqueStrs(field string, values []string) ([]T, error)
+	ListByUniqueStrsCtx(ctx context.Context, field string, values []string) ([]T, error)
 }
 
 // Cache
@@ -132,6 +150,7 @@ type Cache[T Table[I], I IDType] interface {
 	DBCRUD[T, I]
 	//clear cache for objs
 	ClearCache(objs ...T) error
+	ClearCacheCtx(ctx context.Context, objs ...T) error
 
 	//for extending
 
@@ -142,11 +161,20 @@ type Cache[T Table[I], I IDType] interface {
 	GetTableName() string
 	SetIdField(idField string)
 	GetIdField() string
+	SetSerializer(serializer Serializer)
+	GetSerializer() Serializer
+	SetTTLJitter(jitter time.Duration)
+	SetNullTTL(ttl time.Duration)
+	SetObserver(observer Observer)
+	GetObserver() Observer
+	SetLogger(logger Logger)
+	GetLogger() Logger
 }
 
 type FullCache[T Table[I], I IDType] interface {
 	DBCRUD[T, I]
 	ClearCache(objs ...T) error
+	ClearCacheCtx(ctx context.Context, objs ...T) error
 	//Creat create new record into dababase
 	// Create(obj *T) error
 	// //Save update if id exists or create new record
@@ -166,6 +194,7 @@ type FullCache[T Table[I], I IDType] interface {
 	// ListBy(index Index, orderBys OrderBys) ([]T, error)
 	//list all objs from db
 	ListAll() ([]T, error)
+	ListAllCtx(ctx context.Context) ([]T, error)
 
 	//close lower clients
 	Close() error
@@ -180,6 +209,14 @@ type FullCache[T Table[I], I IDType] interface {
 	GetTableName() string
 	SetIdField(idField string)
 	GetIdField() string
+	SetSerializer(serializer Serializer)
+	GetSerializer() Serializer
+	SetTTLJitter(jitter time.Duration)
+	SetNullTTL(ttl time.Duration)
+	SetObserver(observer Observer)
+	GetObserver() Observer
+	SetLogger(logger Logger)
+	GetLogger() Logger
 }
 
 type CacheBase[T Table[I], I IDType] struct {
@@ -191,13 +228,19 @@ type CacheBase[T Table[I], I IDType] struct {
 	// indexFields [][]string
 	// ctx context.Context
 	// ttl         time.Duration
+	// generation is part of every cache key when >0, bumping it invalidates the whole table
+	generation int64
+	// observer optional receiver of cache events
+	observer Observer
+	// logger optional receiver of swallowed errors
+	logger Logger
 }
 
 func NewCacheBase[T Table[I], I IDType](prefix, table, idField string) *CacheBase[T, I] {
 	return &CacheBase[T, I]{
 		prefix:  prefix,
 		table:   table,
-		idField: idField,
+		idField: idFieldOf[T](idField),
 	}
 }
 
@@ -216,6 +259,9 @@ func (s *CacheBase[T, I]) GetCacheKeyPrefix() string {
 }
 func (s *CacheBase[T, I]) MakeCacheKey(index Index) string {
 	r := s.prefix + "/" + s.table
+	if g := s.GetGeneration(); g > 0 {
+		r += "/g" + strconv.FormatInt(g, 10)
+	}
 	keys := index.Fields()
 	sort.Strings(keys)
 	for _, k := range keys {
@@ -224,6 +270,13 @@ func (s *CacheBase[T, I]) MakeCacheKey(index Index) string {
 	return r
 }
 
+func (s *CacheBase[T, I]) SetGeneration(generation int64) {
+	atomic.StoreInt64(&s.generation, generation)
+}
+func (s *CacheBase[T, I]) GetGeneration() int64 {
+	return atomic.LoadInt64(&s.generation)
+}
+
 func (s *CacheBase[T, I]) SetIdField(idField string) {
 	s.idField = idField
 }
@@ -0,0 +1,373 @@
+// scachegen generates scache.Table implementations, typed index constructors and typed cache wrappers of model structs
+// declared by scache struct tags, without reflection. Usage with go:generate:
+//
+//	//go:generate go run github.com/daqiancode/scache/cmd/scachegen -type Commodity,Order
+//
+// For a struct Commodity with a field CategoryId uint64 tagged `scache:"index"`, it generates:
+//
+//	func (s Commodity) GetID() uint64
+//	func (s Commodity) ListIndexes() scache.Indexes
+//	func CommodityByCategoryId(categoryId uint64) scache.Index
+//	type CommodityCache struct{ scache.Cache[Commodity, uint64] }
+//	func (s CommodityCache) ListByCategoryId(categoryId uint64, orderBys scache.OrderBys) ([]Commodity, error)
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/daqiancode/scache"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma separated names of model structs, required")
+	output := flag.String("output", "", "output file, default {go file}_scache.go or scache_gen.go")
+	flag.Parse()
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if args := flag.Args(); len(args) > 0 {
+		dir = args[0]
+	}
+	out := *output
+	if out == "" {
+		out = "scache_gen.go"
+		if f := os.Getenv("GOFILE"); f != "" {
+			out = strings.TrimSuffix(f, ".go") + "_scache.go"
+		}
+		out = filepath.Join(dir, out)
+	}
+	src, err := generate(dir, strings.Split(*typeNames, ","), filepath.Base(out))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "scachegen:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(out, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "scachegen:", err)
+		os.Exit(1)
+	}
+}
+
+// field field of a model struct
+type field struct {
+	Name string
+	Type string
+	// Param parameter name of the field in generated functions
+	Param string
+}
+
+// index index of a model struct, fields in order of declaration
+type index struct {
+	Fields []field
+	Unique bool
+	// Dup the same fields are indexed before, its constructor is generated already
+	Dup bool
+	// DupMethods the same fields are indexed before with the same uniqueness, its cache methods are generated already
+	DupMethods bool
+}
+
+// FuncName suffix of generated function names, eg. UidStatus
+func (s index) FuncName() string {
+	r := ""
+	for _, v := range s.Fields {
+		r += v.Name
+	}
+	return r
+}
+
+// model struct to generate for
+type model struct {
+	Name    string
+	ID      field
+	Indexes []index
+}
+
+// generate parse the package in dir and generate the code of typeNames, skip is the generated file
+func generate(dir string, typeNames []string, skip string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != skip
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expect 1 package in %s, found %d", dir, len(pkgs))
+	}
+	var pkg *ast.Package
+	for _, v := range pkgs {
+		pkg = v
+	}
+	// struct types & imports of their files
+	structs := make(map[string]*ast.StructType)
+	imports := make(map[string]map[string]string)
+	for _, f := range pkg.Files {
+		fileImports := make(map[string]string)
+		for _, v := range f.Imports {
+			path, _ := strconv.Unquote(v.Path.Value)
+			name := filepath.Base(path)
+			if v.Name != nil {
+				name = v.Name.Name
+			}
+			fileImports[name] = path
+		}
+		for _, decl := range f.Decls {
+			g, ok := decl.(*ast.GenDecl)
+			if !ok || g.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range g.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if st, ok := ts.Type.(*ast.StructType); ok {
+					structs[ts.Name.Name] = st
+					imports[ts.Name.Name] = fileImports
+				}
+			}
+		}
+	}
+	used := map[string]string{"scache": "github.com/daqiancode/scache"}
+	var models []model
+	for _, name := range typeNames {
+		name = strings.TrimSpace(name)
+		st, ok := structs[name]
+		if !ok {
+			return nil, fmt.Errorf("struct %s not found in %s", name, dir)
+		}
+		m, err := parseModel(name, st, structs)
+		if err != nil {
+			return nil, err
+		}
+		// imports of field types
+		fields := []field{m.ID}
+		for _, v := range m.Indexes {
+			fields = append(fields, v.Fields...)
+		}
+		for _, v := range fields {
+			for _, pkgName := range typePackages(v.Type) {
+				path, ok := imports[name][pkgName]
+				if !ok {
+					// field of an embedded struct declared in another file
+					for _, fileImports := range imports {
+						if path, ok = fileImports[pkgName]; ok {
+							break
+						}
+					}
+				}
+				if !ok {
+					return nil, fmt.Errorf("import of %s in %s.%s not found", pkgName, name, v.Name)
+				}
+				used[pkgName] = path
+			}
+		}
+		if len(m.Indexes) > 0 {
+			used["context"] = "context"
+		}
+		models = append(models, m)
+	}
+	// standard packages first
+	var std, others []string
+	for name, path := range used {
+		line := strconv.Quote(path)
+		if filepath.Base(path) != name {
+			line = name + " " + line
+		}
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			others = append(others, line)
+		} else {
+			std = append(std, line)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(others)
+	importLines := std
+	if len(std) > 0 {
+		importLines = append(importLines, "")
+	}
+	importLines = append(importLines, others...)
+	var buf bytes.Buffer
+	err = codeTemplate.Execute(&buf, map[string]interface{}{
+		"Package": pkg.Name,
+		"Imports": importLines,
+		"Models":  models,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// parseModel read the id & indexes of a struct from scache tags, fields of embedded structs of the package included
+func parseModel(name string, st *ast.StructType, structs map[string]*ast.StructType) (model, error) {
+	m := model{Name: name}
+	var all []field
+	named := make(map[string]int)
+	var walk func(st *ast.StructType)
+	walk = func(st *ast.StructType) {
+		for _, f := range st.Fields.List {
+			tag := ""
+			if f.Tag != nil {
+				v, _ := strconv.Unquote(f.Tag.Value)
+				tag, _ = reflect.StructTag(v).Lookup("scache")
+			}
+			if len(f.Names) == 0 {
+				// embedded struct of the package
+				if ident, ok := f.Type.(*ast.Ident); ok && structs[ident.Name] != nil && tag == "" {
+					walk(structs[ident.Name])
+				}
+				continue
+			}
+			for _, n := range f.Names {
+				if !n.IsExported() {
+					continue
+				}
+				fd := field{Name: n.Name, Type: types.ExprString(f.Type), Param: paramName(n.Name)}
+				all = append(all, fd)
+				opts := scache.ParseTag(tag)
+				if opts.ID {
+					m.ID = fd
+				}
+				if opts.Index {
+					m.Indexes = append(m.Indexes, index{Fields: []field{fd}})
+				}
+				for _, v := range opts.IndexNames {
+					if j, ok := named[v]; ok {
+						m.Indexes[j].Fields = append(m.Indexes[j].Fields, fd)
+					} else {
+						named[v] = len(m.Indexes)
+						m.Indexes = append(m.Indexes, index{Fields: []field{fd}})
+					}
+				}
+				if opts.Unique {
+					m.Indexes = append(m.Indexes, index{Fields: []field{fd}, Unique: true})
+				}
+			}
+		}
+	}
+	walk(st)
+	seen := make(map[string]bool)
+	seenMethods := make(map[string]bool)
+	for i, v := range m.Indexes {
+		m.Indexes[i].Dup = seen[v.FuncName()]
+		seen[v.FuncName()] = true
+		// a unique index gets GetBy methods, others ListBy & CountBy
+		key := fmt.Sprint(v.FuncName(), v.Unique)
+		m.Indexes[i].DupMethods = seenMethods[key]
+		seenMethods[key] = true
+	}
+	if m.ID.Name == "" {
+		for _, v := range all {
+			if v.Name == "ID" || v.Name == "Id" {
+				m.ID = v
+				break
+			}
+		}
+	}
+	if m.ID.Name == "" {
+		return m, fmt.Errorf("id field of %s not found, tag it with `scache:\"id\"`", name)
+	}
+	return m, nil
+}
+
+// paramName lower camel case name of field which is not a keyword or a name used by generated code
+func paramName(name string) string {
+	r := strings.ToLower(name[:1]) + name[1:]
+	if strings.ToUpper(name) == name {
+		r = strings.ToLower(name)
+	}
+	switch r {
+	case "s", "ctx", "orderBys", "scache", "context":
+		return r + "_"
+	}
+	if token.IsKeyword(r) {
+		return r + "_"
+	}
+	return r
+}
+
+// typePackages package names referred by a type expression, eg. time of *time.Time
+func typePackages(typ string) []string {
+	expr, err := parser.ParseExpr(typ)
+	if err != nil {
+		return nil
+	}
+	var r []string
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok {
+				r = append(r, ident.Name)
+			}
+			return false
+		}
+		return true
+	})
+	return r
+}
+
+var codeTemplate = template.Must(template.New("scache").Parse(`// Code generated by scachegen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{range .Imports}}	{{.}}
+{{end}})
+
+{{range $m := .Models}}
+func (s {{$m.Name}}) GetID() {{$m.ID.Type}} {
+	return s.{{$m.ID.Name}}
+}
+
+func (s {{$m.Name}}) ListIndexes() scache.Indexes {
+	return scache.Indexes{ {{- range .Indexes}}{{if not .Dup}}
+		{{$m.Name}}By{{.FuncName}}({{range .Fields}}s.{{.Name}}, {{end}}),{{end}}{{end}}
+	}
+}
+{{range .Indexes}}{{if not .Dup}}
+// {{$m.Name}}By{{.FuncName}} index of {{$m.Name}} by {{range $i, $f := .Fields}}{{if $i}} & {{end}}{{$f.Name}}{{end}}
+func {{$m.Name}}By{{.FuncName}}({{range .Fields}}{{.Param}} {{.Type}}, {{end}}) scache.Index {
+	return scache.Index{ {{- range .Fields}}"{{.Name}}": {{.Param}}, {{end}}}
+}
+{{end}}{{end}}
+// {{$m.Name}}Cache typed queries of {{$m.Name}} by its indexes
+type {{$m.Name}}Cache struct {
+	scache.Cache[{{$m.Name}}, {{$m.ID.Type}}]
+}
+
+func New{{$m.Name}}Cache(c scache.Cache[{{$m.Name}}, {{$m.ID.Type}}]) {{$m.Name}}Cache {
+	return {{$m.Name}}Cache{Cache: c}
+}
+{{range .Indexes}}{{if not .DupMethods}}{{if .Unique}}
+func (s {{$m.Name}}Cache) GetBy{{.FuncName}}({{range .Fields}}{{.Param}} {{.Type}}, {{end}}) ({{$m.Name}}, error) {
+	return s.GetBy({{$m.Name}}By{{.FuncName}}({{range .Fields}}{{.Param}}, {{end}}))
+}
+
+func (s {{$m.Name}}Cache) GetBy{{.FuncName}}Ctx(ctx context.Context, {{range .Fields}}{{.Param}} {{.Type}}, {{end}}) ({{$m.Name}}, error) {
+	return s.GetByCtx(ctx, {{$m.Name}}By{{.FuncName}}({{range .Fields}}{{.Param}}, {{end}}))
+}
+{{else}}
+func (s {{$m.Name}}Cache) ListBy{{.FuncName}}({{range .Fields}}{{.Param}} {{.Type}}, {{end}}orderBys scache.OrderBys) ([]{{$m.Name}}, error) {
+	return s.ListBy({{$m.Name}}By{{.FuncName}}({{range .Fields}}{{.Param}}, {{end}}), orderBys)
+}
+
+func (s {{$m.Name}}Cache) ListBy{{.FuncName}}Ctx(ctx context.Context, {{range .Fields}}{{.Param}} {{.Type}}, {{end}}orderBys scache.OrderBys) ([]{{$m.Name}}, error) {
+	return s.ListByCtx(ctx, {{$m.Name}}By{{.FuncName}}({{range .Fields}}{{.Param}}, {{end}}), orderBys)
+}
+
+func (s {{$m.Name}}Cache) CountBy{{.FuncName}}({{range .Fields}}{{.Param}} {{.Type}}, {{end}}) (int64, error) {
+	return s.CountBy({{$m.Name}}By{{.FuncName}}({{range .Fields}}{{.Param}}, {{end}}))
+}
+{{end}}{{end}}{{end}}{{end}}`))
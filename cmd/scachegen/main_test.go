@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerate(t *testing.T) {
+	src, err := generate("testdata", []string{"Commodity", "Order", "Coupon"}, "")
+	assert.Nil(t, err)
+	golden := "testdata/models_scache.go.golden"
+	if *update {
+		assert.Nil(t, os.WriteFile(golden, src, 0644))
+	}
+	want, err := os.ReadFile(golden)
+	assert.Nil(t, err)
+	assert.Equal(t, string(want), string(src))
+
+	_, err = generate("testdata", []string{"Missing"}, "")
+	assert.NotNil(t, err)
+}
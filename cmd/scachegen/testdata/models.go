@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+type Base struct {
+	ID        uint64
+	CreatedAt time.Time
+}
+
+type Commodity struct {
+	Base
+	CategoryId uint64 `scache:"index"`
+	Name       string
+}
+
+type Order struct {
+	No     string    `scache:"id"`
+	Uid    uint      `scache:"index,index:uid_status"`
+	Status string    `scache:"index:uid_status"`
+	Type   int       `scache:"index"`
+	Code   string    `scache:"index,unique"`
+	PaidAt time.Time `scache:"unique"`
+}
+
+type Coupon struct {
+	ID  uint
+	Uid uint `scache:"index,index:solo"`
+}
@@ -0,0 +1,145 @@
+package scache_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/scache"
+	"github.com/daqiancode/scache/scachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+type member struct {
+	Id    int
+	Group int
+}
+
+func (s member) GetID() int { return s.Id }
+func (s member) ListIndexes() scache.Indexes {
+	return scache.Indexes{scache.NewIndex("Group", s.Group)}
+}
+
+// gatedDB counts reads, which block until the gate is opened or ctx is done
+type gatedDB struct {
+	*scachetest.MemoryDB[member, int]
+	gate  chan struct{}
+	calls int64
+}
+
+func (s *gatedDB) wait(ctx context.Context) error {
+	atomic.AddInt64(&s.calls, 1)
+	select {
+	case <-s.gate:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *gatedDB) GetCtx(ctx context.Context, id int) (member, error) {
+	if err := s.wait(ctx); err != nil {
+		return member{}, err
+	}
+	return s.MemoryDB.GetCtx(ctx, id)
+}
+
+func (s *gatedDB) GetByCtx(ctx context.Context, index scache.Index) (member, error) {
+	if err := s.wait(ctx); err != nil {
+		return member{}, err
+	}
+	return s.MemoryDB.GetByCtx(ctx, index)
+}
+
+func (s *gatedDB) ListByCtx(ctx context.Context, index scache.Index, orderBys scache.OrderBys) ([]member, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	return s.MemoryDB.ListByCtx(ctx, index, orderBys)
+}
+
+func newGatedCache(t *testing.T) (*scache.RedisCache[member, int], *gatedDB) {
+	red, _ := scachetest.NewRedis(t)
+	db := &gatedDB{MemoryDB: scachetest.NewMemoryDB[member, int]("Id"), gate: make(chan struct{})}
+	assert.Nil(t, db.MemoryDB.Create(&member{Id: 1, Group: 1}))
+	return scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute), db
+}
+
+// openGate open the gate once n reads are blocked on it, and the other callers had time to join them
+func openGate(t *testing.T, db *gatedDB, n int64) {
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt64(&db.calls) < n && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(db.gate)
+}
+
+func TestCoalesceGet(t *testing.T) {
+	ca, db := newGatedCache(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := ca.Get(1)
+			assert.Nil(t, err)
+			assert.Equal(t, member{Id: 1, Group: 1}, r)
+		}()
+	}
+	openGate(t, db, 1)
+	wg.Wait()
+	assert.Equal(t, int64(1), atomic.LoadInt64(&db.calls))
+}
+
+func TestCoalesceGetByAndListBy(t *testing.T) {
+	ca, db := newGatedCache(t)
+	index := scache.NewIndex("Group", 1)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			r, err := ca.GetBy(index)
+			assert.Nil(t, err)
+			assert.Equal(t, member{Id: 1, Group: 1}, r)
+		}()
+		go func() {
+			defer wg.Done()
+			rs, err := ca.ListBy(index, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, []member{{Id: 1, Group: 1}}, rs)
+		}()
+	}
+	// one flight per operation
+	openGate(t, db, 2)
+	wg.Wait()
+	assert.Equal(t, int64(2), atomic.LoadInt64(&db.calls))
+}
+
+func TestCoalesceCanceledLeader(t *testing.T) {
+	ca, db := newGatedCache(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error)
+	go func() {
+		_, err := ca.GetCtx(ctx, 1)
+		leader <- err
+	}()
+	for atomic.LoadInt64(&db.calls) < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	waiter := make(chan error)
+	go func() {
+		r, err := ca.Get(1)
+		assert.Equal(t, member{Id: 1, Group: 1}, r)
+		waiter <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-leader, context.Canceled)
+	close(db.gate)
+	assert.Nil(t, <-waiter)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&db.calls))
+}
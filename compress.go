@@ -0,0 +1,123 @@
+package scache
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// header bytes of payloads written by CompressSerializer.
+// 0xC1 is never used by msgpack, and none of them can start a json or gob payload,
+// so payloads written before compression was enabled are decoded by the inner serializer as is.
+const (
+	headerRaw    byte = 0xC1
+	headerSnappy byte = 0xC2
+	headerZstd   byte = 0xC3
+)
+
+// DefaultCompressThreshold payloads shorter than it are stored uncompressed
+var DefaultCompressThreshold = 1024
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+func initZstd() error {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil)
+		if zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+	return zstdErr
+}
+
+// CompressSerializer compress payloads of the inner serializer which are not shorter than threshold.
+// Every payload has a header byte telling whether and how it is compressed,
+// both snappy and zstd payloads are decoded whatever the serializer compresses with.
+type CompressSerializer struct {
+	Serializer
+	header    byte
+	threshold int
+}
+
+// NewSnappySerializer compress with snappy, fast with moderate ratio. threshold<=0 means DefaultCompressThreshold
+func NewSnappySerializer(inner Serializer, threshold int) *CompressSerializer {
+	return newCompressSerializer(inner, headerSnappy, threshold)
+}
+
+// NewZstdSerializer compress with zstd, better ratio with more cpu. threshold<=0 means DefaultCompressThreshold
+func NewZstdSerializer(inner Serializer, threshold int) *CompressSerializer {
+	return newCompressSerializer(inner, headerZstd, threshold)
+}
+
+func newCompressSerializer(inner Serializer, header byte, threshold int) *CompressSerializer {
+	if threshold <= 0 {
+		threshold = DefaultCompressThreshold
+	}
+	return &CompressSerializer{Serializer: inner, header: header, threshold: threshold}
+}
+
+func (s *CompressSerializer) Marshal(obj interface{}) (string, error) {
+	data, err := s.Serializer.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < s.threshold {
+		return withHeader(headerRaw, []byte(data)), nil
+	}
+	var compressed []byte
+	switch s.header {
+	case headerSnappy:
+		compressed = snappy.Encode(nil, []byte(data))
+	case headerZstd:
+		if err := initZstd(); err != nil {
+			return "", err
+		}
+		compressed = zstdEncoder.EncodeAll([]byte(data), nil)
+	}
+	// incompressible payload
+	if len(compressed) >= len(data) {
+		return withHeader(headerRaw, []byte(data)), nil
+	}
+	return withHeader(s.header, compressed), nil
+}
+
+func withHeader(header byte, payload []byte) string {
+	b := make([]byte, 0, len(payload)+1)
+	b = append(b, header)
+	return string(append(b, payload...))
+}
+
+func (s *CompressSerializer) Unmarshal(data string, objRef interface{}) error {
+	if len(data) == 0 {
+		return s.Serializer.Unmarshal(data, objRef)
+	}
+	switch data[0] {
+	case headerRaw:
+		return s.Serializer.Unmarshal(data[1:], objRef)
+	case headerSnappy:
+		raw, err := snappy.Decode(nil, []byte(data[1:]))
+		if err != nil {
+			return fmt.Errorf("snappy decode: %w", err)
+		}
+		return s.Serializer.Unmarshal(string(raw), objRef)
+	case headerZstd:
+		if err := initZstd(); err != nil {
+			return err
+		}
+		raw, err := zstdDecoder.DecodeAll([]byte(data[1:]), nil)
+		if err != nil {
+			return fmt.Errorf("zstd decode: %w", err)
+		}
+		return s.Serializer.Unmarshal(string(raw), objRef)
+	}
+	// written before compression was enabled
+	return s.Serializer.Unmarshal(data, objRef)
+}
@@ -0,0 +1,76 @@
+package scache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CountKey redis key of the number of records by index, deleted with the index key
+func (s *RedisCache[T, I]) CountKey(index Index) string {
+	return s.MakeCacheKey(index) + "/count"
+}
+
+// CountBy count records by index, the count is cached
+func (s *RedisCache[T, I]) CountBy(index Index) (int64, error) {
+	return s.CountByCtx(context.Background(), index)
+}
+
+func (s *RedisCache[T, I]) CountByCtx(ctx context.Context, index Index) (int64, error) {
+	s.syncGeneration(ctx)
+	if !s.cacheAvailable(ctx) {
+		return s.db.CountByCtx(ctx, index)
+	}
+	redisKey := s.CountKey(index)
+	start := time.Now()
+	r, h, err := s.redCount.getJsonCtx(ctx, redisKey)
+	if err != nil && err != redis.Nil {
+		s.observe("CountBy", EventError, 1, start, err)
+		if s.cacheFailed(ctx, err) {
+			return s.db.CountByCtx(ctx, index)
+		}
+		return r, err
+	}
+	s.cacheSucceeded()
+	if err == nil {
+		s.observeHit("CountBy", h, start)
+		s.touch(ctx, redisKey)
+		return r, nil
+	}
+	s.observe("CountBy", EventMiss, 1, start, nil)
+	v, err, _ := s.do(ctx, "count:"+redisKey, func(ctx context.Context) (interface{}, error) {
+		l, filled, err := s.acquireLease(ctx, redisKey)
+		if err != nil {
+			if !s.cacheFailed(ctx, err) {
+				return r, err
+			}
+			l = &lease{}
+		}
+		if filled {
+			if r, _, err := s.redCount.getJsonCtx(ctx, redisKey); err == nil {
+				return r, nil
+			}
+		}
+		return s.loadCount(ctx, index, redisKey, l)
+	})
+	r, _ = v.(int64)
+	return r, err
+}
+
+// loadCount count records by index in db and fill the count into redis
+func (s *RedisCache[T, I]) loadCount(ctx context.Context, index Index, redisKey string, l *lease) (int64, error) {
+	start := time.Now()
+	r, err := s.db.CountByCtx(ctx, index)
+	s.observe("CountBy", EventDBLoad, 1, start, err)
+	if err != nil {
+		return r, err
+	}
+	start = time.Now()
+	err = s.redCount.setJsonLeased(ctx, redisKey, r, l)
+	s.observe("CountBy", EventSet, 1, start, err)
+	if err != nil && !s.cacheFailed(ctx, err) {
+		return r, err
+	}
+	return r, nil
+}
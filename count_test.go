@@ -0,0 +1,40 @@
+package scache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/scache"
+	"github.com/daqiancode/scache/scachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountBy(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	db := scachetest.NewMemoryDB[member, int]("Id")
+	assert.Nil(t, db.Create(&member{Id: 1, Group: 1}))
+	assert.Nil(t, db.Create(&member{Id: 2, Group: 1}))
+	ca := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	index := scache.NewIndex("Group", 1)
+
+	n, err := ca.CountBy(index)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.True(t, mr.Exists(ca.CountKey(index)))
+	// served from cache
+	assert.Nil(t, db.Create(&member{Id: 3, Group: 1}))
+	n, err = ca.CountBy(index)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+
+	// a write to a record of the index clears the count
+	_, err = ca.Update(1, map[string]interface{}{"Group": 2})
+	assert.Nil(t, err)
+	assert.False(t, mr.Exists(ca.CountKey(index)))
+	n, err = ca.CountBy(index)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+	n, err = ca.CountBy(scache.NewIndex("Group", 2))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+}
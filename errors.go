@@ -0,0 +1,61 @@
+package scache
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrCacheUnavailable redis failed, eg. timeout or connection refused, the underlying error is wrapped too
+	ErrCacheUnavailable = errors.New("cache unavailable")
+	// ErrDecode a cached entry can't be decoded by the serializer
+	ErrDecode = errors.New("decode cached entry failed")
+	// ErrNotSupported the operation is not supported by the cache or db
+	ErrNotSupported = errors.New("not supported")
+	// ErrInvalidCursor the cursor of ListByCursor is malformed
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrWrongType a key of MemoryStore holds another type of value, eg. HGet of a string key
+	ErrWrongType = errors.New("wrong type of value")
+)
+
+// DBError a db operation failed, errors.Is/As see the underlying error of the driver
+type DBError struct {
+	// Op db method, eg. Get, ListBy
+	Op    string
+	Table string
+	Err   error
+}
+
+func (e *DBError) Error() string {
+	return "db " + e.Op + " " + e.Table + ": " + e.Err.Error()
+}
+
+func (e *DBError) Unwrap() error {
+	return e.Err
+}
+
+// NewDBError wrap err of a db operation, nil and ErrRecordNotFound are returned as is
+func NewDBError(op, table string, err error) error {
+	if err == nil || err == ErrRecordNotFound {
+		return err
+	}
+	return &DBError{Op: op, Table: table, Err: err}
+}
+
+// cacheError wrap redis failures with ErrCacheUnavailable, redis.Nil is returned as is
+func cacheError(err error) error {
+	if err == nil || err == redis.Nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrCacheUnavailable, err)
+}
+
+// decodeError wrap serializer failures with ErrDecode
+func decodeError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrDecode, err)
+}
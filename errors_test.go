@@ -0,0 +1,76 @@
+package scache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/scache"
+	"github.com/daqiancode/scache/scachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDBError(t *testing.T) {
+	assert.Nil(t, scache.NewDBError("Get", "user", nil))
+	assert.Equal(t, scache.ErrRecordNotFound, scache.NewDBError("Get", "user", scache.ErrRecordNotFound))
+
+	driverErr := errors.New("connection reset")
+	err := scache.NewDBError("ListBy", "user", driverErr)
+	assert.True(t, errors.Is(err, driverErr))
+	var dbErr *scache.DBError
+	assert.True(t, errors.As(err, &dbErr))
+	assert.Equal(t, "ListBy", dbErr.Op)
+	assert.Equal(t, "db ListBy user: connection reset", err.Error())
+	assert.False(t, errors.Is(err, scache.ErrCacheUnavailable))
+}
+
+// failingDB fails record loads like a db driver
+type failingDB struct {
+	*scachetest.MemoryDB[member, int]
+	err error
+}
+
+func (s *failingDB) GetCtx(ctx context.Context, id int) (member, error) {
+	return member{}, scache.NewDBError("Get", "member", s.err)
+}
+
+func TestCacheErrors(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	db := scachetest.NewMemoryDB[member, int]("Id")
+	assert.Nil(t, db.Create(&member{Id: 1, Group: 1}))
+	ca := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+
+	// corrupt entries
+	assert.Nil(t, mr.Set(ca.MakeCacheKey(scache.NewIndex("Id", 1)), "{"))
+	_, err := ca.Get(1)
+	assert.ErrorIs(t, err, scache.ErrDecode)
+	assert.Nil(t, mr.Set(ca.MakeCacheKey(scache.NewIndex("Group", 1)), "{"))
+	_, err = ca.ListBy(scache.NewIndex("Group", 1), nil)
+	assert.ErrorIs(t, err, scache.ErrDecode)
+	r := scache.NewRedisJson[member](red, time.Minute)
+	assert.Nil(t, mr.Set("corrupt", "{"))
+	_, err = r.GetJson("corrupt")
+	assert.ErrorIs(t, err, scache.ErrDecode)
+
+	assert.ErrorIs(t, ca.InvalidateAll(), scache.ErrNotSupported)
+
+	// redis failures
+	mr.SetError("boom")
+	_, err = ca.Get(1)
+	assert.ErrorIs(t, err, scache.ErrCacheUnavailable)
+	_, err = ca.List(1)
+	assert.ErrorIs(t, err, scache.ErrCacheUnavailable)
+	assert.ErrorIs(t, ca.ClearCache(member{Id: 1, Group: 1}), scache.ErrCacheUnavailable)
+	assert.ErrorIs(t, r.Del("corrupt"), scache.ErrCacheUnavailable)
+	mr.SetError("")
+
+	// db failures
+	driverErr := errors.New("connection reset")
+	ca = scache.NewRedisCache[member, int]("test", "member", "Id", &failingDB{MemoryDB: db, err: driverErr}, red, time.Minute)
+	_, err = ca.Get(2)
+	assert.ErrorIs(t, err, driverErr)
+	var dbErr *scache.DBError
+	assert.ErrorAs(t, err, &dbErr)
+	assert.False(t, errors.Is(err, scache.ErrCacheUnavailable))
+}
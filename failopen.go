@@ -0,0 +1,161 @@
+package scache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FailOpenOptions reading through to db while redis is unavailable
+type FailOpenOptions struct {
+	// Threshold consecutive redis failures opening the circuit breaker, <=0 means 5
+	Threshold int
+	// ProbeInterval while the breaker is open redis is skipped, and probed with PING at most once per interval. <=0 means 1s.
+	ProbeInterval time.Duration
+}
+
+// breaker circuit breaker of redis
+type breaker struct {
+	threshold int64
+	interval  time.Duration
+	failures  int64
+	// open 1 means redis is skipped
+	open int32
+	mu   sync.Mutex
+	// nextProbe earliest time of the next probe while open
+	nextProbe time.Time
+	probing   bool
+}
+
+// EnableFailOpen on redis failures reads fall through to db without filling the cache, and invalidations after writes are retried in background,
+// by an in-process retry queue unless EnableRetryQueue was called. After Threshold consecutive failures redis is skipped until a probe succeeds
+func (s *RedisCache[T, I]) EnableFailOpen(opts FailOpenOptions) {
+	if opts.Threshold <= 0 {
+		opts.Threshold = 5
+	}
+	if opts.ProbeInterval <= 0 {
+		opts.ProbeInterval = time.Second
+	}
+	s.breaker = &breaker{threshold: int64(opts.Threshold), interval: opts.ProbeInterval}
+	if s.retry == nil {
+		s.retry = NewRetryQueue(s.retryDel, RetryOptions{MinBackoff: opts.ProbeInterval})
+		s.retry.SetLogger(s.logger)
+	}
+}
+
+// cacheAvailable whether redis should be used. While the breaker is open, one caller per ProbeInterval probes redis
+func (s *RedisCache[T, I]) cacheAvailable(ctx context.Context) bool {
+	b := s.breaker
+	if b == nil || atomic.LoadInt32(&b.open) == 0 {
+		return true
+	}
+	b.mu.Lock()
+	if b.probing || time.Now().Before(b.nextProbe) {
+		b.mu.Unlock()
+		return false
+	}
+	b.probing = true
+	b.mu.Unlock()
+
+	ok := s.probe(ctx)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if !ok {
+		b.nextProbe = time.Now().Add(b.interval)
+		return false
+	}
+	atomic.StoreInt64(&b.failures, 0)
+	atomic.StoreInt32(&b.open, 0)
+	return true
+}
+
+// probe ping redis, and flush pending invalidations before serving reads again
+func (s *RedisCache[T, I]) probe(ctx context.Context) bool {
+	redCtx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	if err := s.red.store.Ping(redCtx); err != nil {
+		return false
+	}
+	if s.retry == nil {
+		return true
+	}
+	// the breaker is still open, delete directly.
+	// A running flush, eg. the retry probing now, deletes the pending keys itself
+	_, err := s.retry.tryFlush(ctx, s.delKeys)
+	return err == nil
+}
+
+// cacheFailed record a redis failure, return whether the caller should fall back to db
+func (s *RedisCache[T, I]) cacheFailed(ctx context.Context, err error) bool {
+	b := s.breaker
+	// canceled requests say nothing about redis, and can't read db either
+	if b == nil || !errors.Is(err, ErrCacheUnavailable) || ctx.Err() != nil {
+		return false
+	}
+	if atomic.AddInt64(&b.failures, 1) >= b.threshold && atomic.CompareAndSwapInt32(&b.open, 0, 1) {
+		b.mu.Lock()
+		b.nextProbe = time.Now().Add(b.interval)
+		b.mu.Unlock()
+		s.warn("scache: redis unavailable, circuit breaker opened", err)
+	}
+	return true
+}
+
+// cacheSkipped whether the breaker is open, without probing
+func (s *RedisCache[T, I]) cacheSkipped() bool {
+	return s.breaker != nil && atomic.LoadInt32(&s.breaker.open) == 1
+}
+
+// cacheSucceeded reset consecutive failures
+func (s *RedisCache[T, I]) cacheSucceeded() {
+	if b := s.breaker; b != nil && atomic.LoadInt64(&b.failures) != 0 {
+		atomic.StoreInt64(&b.failures, 0)
+	}
+}
+
+// clearOrQueue delete keys, queue them for retry on failure, or without trying while the circuit breaker is open
+func (s *RedisCache[T, I]) clearOrQueue(ctx context.Context, keys []string) error {
+	if !s.cacheAvailable(ctx) {
+		// don't serve stale local entries meanwhile
+		if s.local != nil {
+			s.local.Del(keys...)
+		}
+		s.queueInvalidation(ctx, keys)
+		return nil
+	}
+	err := s.delKeys(ctx, keys)
+	if err == nil {
+		s.cacheSucceeded()
+		return nil
+	}
+	s.cacheFailed(ctx, err)
+	s.queueInvalidation(ctx, keys)
+	return err
+}
+
+// queueInvalidation retry deleting keys in background if the retry queue is enabled
+func (s *RedisCache[T, I]) queueInvalidation(ctx context.Context, keys []string) {
+	if s.retry != nil {
+		s.retry.Add(ctx, keys...)
+	}
+}
+
+// listFromDB list records by ids from db, order & empty records keeped like ListCtx
+func (s *RedisCache[T, I]) listFromDB(ctx context.Context, ids []I) ([]T, error) {
+	rs, err := s.db.ListCtx(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[I]T, len(rs))
+	for _, v := range rs {
+		byId[v.GetID()] = v
+	}
+	r := make([]T, len(ids))
+	for i, id := range ids {
+		r[i] = byId[id]
+	}
+	return r, nil
+}
@@ -0,0 +1,72 @@
+package scache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/scache"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+type failOpenUser struct {
+	Id   int
+	Name string
+}
+
+func (s failOpenUser) GetID() int                  { return s.Id }
+func (s failOpenUser) ListIndexes() scache.Indexes { return nil }
+
+// failOpenDB serves Get and List only
+type failOpenDB struct {
+	scache.DBCRUD[failOpenUser, int]
+	rows map[int]failOpenUser
+}
+
+func (s *failOpenDB) GetCtx(ctx context.Context, id int) (failOpenUser, error) {
+	if r, ok := s.rows[id]; ok {
+		return r, nil
+	}
+	return failOpenUser{}, scache.ErrRecordNotFound
+}
+
+func (s *failOpenDB) Close() error {
+	return nil
+}
+
+func (s *failOpenDB) ListCtx(ctx context.Context, ids ...int) ([]failOpenUser, error) {
+	var r []failOpenUser
+	for _, id := range ids {
+		if v, ok := s.rows[id]; ok {
+			r = append(r, v)
+		}
+	}
+	return r, nil
+}
+
+func TestFailOpen(t *testing.T) {
+	// nothing listens on port 1
+	red := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	db := &failOpenDB{rows: map[int]failOpenUser{1: {Id: 1, Name: "tom"}}}
+	ca := scache.NewRedisCache[failOpenUser, int]("test", "user", "Id", db, red, time.Minute)
+
+	_, err := ca.Get(1)
+	assert.True(t, errors.Is(err, scache.ErrCacheUnavailable))
+
+	ca.EnableFailOpen(scache.FailOpenOptions{Threshold: 1, ProbeInterval: time.Hour})
+	r, err := ca.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "tom", r.Name)
+	// breaker is open, redis is skipped
+	rs, err := ca.List(2, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []failOpenUser{{}, {Id: 1, Name: "tom"}}, rs)
+	_, err = ca.Get(2)
+	assert.Equal(t, scache.ErrRecordNotFound, err)
+
+	assert.Nil(t, ca.ClearCache(r))
+	assert.Equal(t, 1, ca.PendingInvalidations())
+	assert.Nil(t, ca.Close())
+}
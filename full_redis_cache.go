@@ -2,7 +2,7 @@ package scache
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -18,6 +18,7 @@ type FullDBCache[T Table[I], I IDType] interface {
 	// Get(id I) (T, bool, error)
 	// List(ids ...I) ([]T, error)
 	ListAll() ([]T, error)
+	ListAllCtx(ctx context.Context) ([]T, error)
 	// Close() error
 }
 
@@ -25,20 +26,64 @@ type FullRedisCache[T Table[I], I IDType] struct {
 	*CacheBase[T, I]
 	db     FullDBCache[T, I]
 	red    *RedisHashJson[T, I]
-	ctx    context.Context
 	redId  *RedisJson[I]
 	redIds *RedisJson[[]I]
 }
 
-func NewFullRedisCache[T Table[I], I IDType](prefix, table, idField string, db FullDBCache[T, I], red *redis.Client, ttl time.Duration) *FullRedisCache[T, I] {
-	return &FullRedisCache[T, I]{
-		CacheBase: &CacheBase[T, I]{prefix: prefix, table: table, idField: idField},
+func NewFullRedisCache[T Table[I], I IDType](prefix, table, idField string, db FullDBCache[T, I], red *redis.Client, ttl time.Duration, opts ...Option) *FullRedisCache[T, I] {
+	return NewFullRedisCacheWithStore(prefix, table, idField, db, NewRedisStore(red), ttl, opts...)
+}
+
+// NewFullRedisCacheWithStore like NewFullRedisCache, entries are kept in store
+func NewFullRedisCacheWithStore[T Table[I], I IDType](prefix, table, idField string, db FullDBCache[T, I], store Store, ttl time.Duration, opts ...Option) *FullRedisCache[T, I] {
+	r := &FullRedisCache[T, I]{
+		CacheBase: &CacheBase[T, I]{prefix: prefix, table: table, idField: idFieldOf[T](idField)},
 		db:        db,
-		red:       NewRedisHashJson[T, I](red, ttl),
-		ctx:       context.Background(),
-		redId:     NewRedisJson[I](red, ttl),
-		redIds:    NewRedisJson[[]I](red, ttl),
+		red:       NewRedisHashJsonWithStore[T, I](store, ttl),
+		redId:     NewRedisJsonWithStore[I](store, ttl),
+		redIds:    NewRedisJsonWithStore[[]I](store, ttl),
 	}
+	if o := newOptions(opts); o.serializer != nil {
+		r.SetSerializer(o.serializer)
+	}
+	return r
+}
+
+// SetSerializer change the encoding of cached objects, default is JsonSerializer
+func (s *FullRedisCache[T, I]) SetSerializer(serializer Serializer) {
+	s.red.SetSerializer(serializer)
+	s.redId.SetSerializer(serializer)
+	s.redIds.SetSerializer(serializer)
+}
+func (s *FullRedisCache[T, I]) GetSerializer() Serializer {
+	return s.red.GetSerializer()
+}
+
+// SetLogger report swallowed errors and skipped undecodable entries to logger, nil to disable
+func (s *FullRedisCache[T, I]) SetLogger(logger Logger) {
+	s.CacheBase.SetLogger(logger)
+	s.red.SetLogger(logger)
+}
+
+// touch refresh ttl of keys, failures are logged only
+func (s *FullRedisCache[T, I]) touch(ctx context.Context, keys ...string) {
+	if err := s.red.ExpiresCtx(ctx, keys...); err != nil {
+		s.warn("scache: refresh ttl failed", err, "keys", keys)
+	}
+}
+
+// SetTTLJitter add a random duration in [0,jitter) to every ttl written
+func (s *FullRedisCache[T, I]) SetTTLJitter(jitter time.Duration) {
+	s.red.SetTTLJitter(jitter)
+	s.redId.SetTTLJitter(jitter)
+	s.redIds.SetTTLJitter(jitter)
+}
+
+// SetNullTTL ttl of cached not found indexes, 0 means ttl
+func (s *FullRedisCache[T, I]) SetNullTTL(ttl time.Duration) {
+	s.red.SetNullTTL(ttl)
+	s.redId.SetNullTTL(ttl)
+	s.redIds.SetNullTTL(ttl)
 }
 
 func (s *FullRedisCache[T, I]) CacheKey() string {
@@ -47,33 +92,52 @@ func (s *FullRedisCache[T, I]) CacheKey() string {
 }
 
 func (s *FullRedisCache[T, I]) Load() error {
-	r, err := s.db.ListAll()
+	return s.LoadCtx(context.Background())
+}
+
+func (s *FullRedisCache[T, I]) LoadCtx(ctx context.Context) error {
+	start := time.Now()
+	r, err := s.db.ListAllCtx(ctx)
+	s.observe("Load", EventDBLoad, len(r), start, err)
 	if err != nil {
 		return err
 	}
 
 	key := s.CacheKey()
-	err = s.red.HSetJson(key, r...)
+	start = time.Now()
+	err = s.red.HSetJsonCtx(ctx, key, r...)
+	s.observe("Load", EventSet, len(r), start, err)
 	if err != nil {
 		return err
 	}
-	return s.red.Expire(s.ctx, key, s.red.ttl).Err()
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	return cacheError(s.red.store.Expire(ctx, map[string]time.Duration{key: s.red.expiration()}))
 }
 
 func (s *FullRedisCache[T, I]) Get(id I) (T, error) {
+	return s.GetCtx(context.Background(), id)
+}
+
+func (s *FullRedisCache[T, I]) GetCtx(ctx context.Context, id I) (T, error) {
 	key := s.CacheKey()
-	r, err := s.red.HGetJson(key, id)
-	if err != nil {
-		return r, err
-	}
+	start := time.Now()
+	r, err := s.red.HGetJsonCtx(ctx, key, id)
 	if err == nil {
+		s.observe("Get", EventHit, 1, start, nil)
 		return r, nil
 	}
-	if err := s.Load(); err != nil {
+	if err != redis.Nil {
+		s.observe("Get", EventError, 1, start, err)
+		return r, err
+	}
+	s.observe("Get", EventMiss, 1, start, nil)
+	// the hash is loaded as a whole, a missing field of a loaded hash is a missing record
+	if err := s.ensureLoaded(ctx, key); err != nil {
 		return r, err
 	}
-	s.red.Expires(key)
-	r, err = s.red.HGetJson(key, id)
+	s.touch(ctx, key)
+	r, err = s.red.HGetJsonCtx(ctx, key, id)
 	if err == redis.Nil {
 		return r, ErrRecordNotFound
 	}
@@ -81,128 +145,265 @@ func (s *FullRedisCache[T, I]) Get(id I) (T, error) {
 }
 
 func (s *FullRedisCache[T, I]) List(id ...I) ([]T, error) {
+	return s.ListCtx(context.Background(), id...)
+}
+
+func (s *FullRedisCache[T, I]) ListCtx(ctx context.Context, id ...I) ([]T, error) {
 	key := s.CacheKey()
-	count, err := s.red.Exists(s.ctx, key).Result()
-	if err != nil {
+	if err := s.ensureLoaded(ctx, key); err != nil {
 		return nil, err
 	}
-	if count == 0 {
-		if err := s.Load(); err != nil {
-			return nil, err
-		}
+	s.touch(ctx, key)
+	start := time.Now()
+	r, err := s.red.HMGetJsonCtx(ctx, key, id...)
+	if err != nil {
+		s.observe("List", EventError, len(id), start, err)
+		return r, err
 	}
-	s.red.Expires(key)
-	return s.red.HMGetJson(key, id...)
+	s.observeBatch("List", len(id), len(id)-len(r), 0, start)
+	return r, nil
+}
+
+// ensureLoaded load full data into redis if the hash key is missing
+func (s *FullRedisCache[T, I]) ensureLoaded(ctx context.Context, key string) error {
+	redCtx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	start := time.Now()
+	exists, err := s.red.store.Exists(redCtx, key)
+	err = cacheError(err)
+	if err != nil {
+		s.observe("Load", EventError, 1, start, err)
+		return err
+	}
+	if !exists {
+		s.observe("Load", EventMiss, 1, start, nil)
+		return s.LoadCtx(ctx)
+	}
+	return nil
 }
 
 func (s *FullRedisCache[T, I]) Create(r *T) error {
-	if err := s.db.Create(r); err != nil {
+	return s.CreateCtx(context.Background(), r)
+}
+
+func (s *FullRedisCache[T, I]) CreateCtx(ctx context.Context, r *T) error {
+	if err := s.db.CreateCtx(ctx, r); err != nil {
 		return err
 	}
-	return s.red.HSetJson(s.CacheKey(), *r)
+	s.clearIndexes(ctx, *r)
+	return s.red.HSetJsonCtx(ctx, s.CacheKey(), *r)
 }
 func (s *FullRedisCache[T, I]) Save(r *T) error {
-	_, err := s.Get((*r).GetID())
+	return s.SaveCtx(context.Background(), r)
+}
+
+func (s *FullRedisCache[T, I]) SaveCtx(ctx context.Context, r *T) error {
+	old, err := s.GetCtx(ctx, (*r).GetID())
 	if err != nil && err != ErrRecordNotFound {
 		return err
 	}
 	if IsNullID((*r).GetID()) || err == ErrRecordNotFound {
-		if err := s.db.Create(r); err != nil {
+		if err := s.db.CreateCtx(ctx, r); err != nil {
 			return err
 		}
 	} else {
-		if err := s.db.Save(r); err != nil {
+		if err := s.db.SaveCtx(ctx, r); err != nil {
 			return err
 		}
 	}
-	return s.red.HSetJson(s.CacheKey(), *r)
+	s.clearIndexes(ctx, old, *r)
+	return s.red.HSetJsonCtx(ctx, s.CacheKey(), *r)
 }
 func (s *FullRedisCache[T, I]) Update(id I, values interface{}) (int64, error) {
+	return s.UpdateCtx(context.Background(), id, values)
+}
+
+func (s *FullRedisCache[T, I]) UpdateCtx(ctx context.Context, id I, values interface{}) (int64, error) {
 	if IsNullID(id) {
 		return 0, nil
 	}
-
-	effectedRows, err := s.db.Update(id, values)
+	old, err := s.GetCtx(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	effectedRows, err := s.db.UpdateCtx(ctx, id, values)
 	if err != nil {
 		return 0, err
 	}
-	r, err := s.db.Get(id)
+	r, err := s.db.GetCtx(ctx, id)
 	if err != nil {
 		return 0, err
 	}
-	return effectedRows, s.red.HSetJson(s.CacheKey(), r)
+	s.clearIndexes(ctx, old, r)
+	return effectedRows, s.red.HSetJsonCtx(ctx, s.CacheKey(), r)
 }
 func (s *FullRedisCache[T, I]) Delete(ids ...I) (int64, error) {
-	rowsAffected, err := s.db.Delete(ids...)
+	return s.DeleteCtx(context.Background(), ids...)
+}
+
+func (s *FullRedisCache[T, I]) DeleteCtx(ctx context.Context, ids ...I) (int64, error) {
+	objs, err := s.ListCtx(ctx, ids...)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := s.db.DeleteCtx(ctx, ids...)
 	if err != nil {
 		return 0, err
 	}
-	s.red.HDelJson(s.CacheKey(), ids...)
+	s.clearIndexes(ctx, objs...)
+	if err := s.red.HDelJsonCtx(ctx, s.CacheKey(), ids...); err != nil {
+		s.warn("scache: clear cache after write failed", err, "ids", ids)
+	}
 	return rowsAffected, err
 }
 
 func (s *FullRedisCache[T, I]) ListAll() ([]T, error) {
+	return s.ListAllCtx(context.Background())
+}
+
+func (s *FullRedisCache[T, I]) ListAllCtx(ctx context.Context) ([]T, error) {
 	key := s.CacheKey()
-	count, err := s.red.Exists(s.ctx, key).Result()
-	if err != nil {
+	if err := s.ensureLoaded(ctx, key); err != nil {
 		return nil, err
 	}
-	if count == 0 {
-		if err := s.Load(); err != nil {
-			return nil, err
-		}
+	s.touch(ctx, key)
+	start := time.Now()
+	r, err := s.red.HGetAllJsonCtx(ctx, key)
+	if err != nil {
+		s.observe("ListAll", EventError, 1, start, err)
+		return r, err
 	}
-	s.red.Expires(key)
-	return s.red.HGetAllJson(key)
+	s.observe("ListAll", EventHit, len(r), start, nil)
+	return r, nil
 }
 
 func (s *FullRedisCache[T, I]) ClearCache(objs ...T) error {
-	return s.red.Del(s.ctx, s.CacheKey()).Err()
+	return s.ClearCacheCtx(context.Background(), objs...)
+}
+
+func (s *FullRedisCache[T, I]) ClearCacheCtx(ctx context.Context, objs ...T) error {
+	redCtx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	start := time.Now()
+	err := cacheError(s.red.store.Del(redCtx, s.CacheKey()))
+	s.observe("ClearCache", EventInvalidation, 1, start, err)
+	if err != nil {
+		return err
+	}
+	return s.delIndexes(ctx, s.indexKeys(objs...))
+}
+
+// indexKeys redis keys of the indexes of objs
+func (s *FullRedisCache[T, I]) indexKeys(objs ...T) []string {
+	var keys []string
+	for _, v := range objs {
+		for _, u := range v.ListIndexes() {
+			keys = append(keys, s.MakeCacheKey(u))
+		}
+	}
+	return UniqueStrings(keys)
+}
+
+// delIndexes delete index keys with their variants
+func (s *FullRedisCache[T, I]) delIndexes(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	start := time.Now()
+	_, err := deleteKeys(ctx, s.red.store, keys, false)
+	err = cacheError(err)
+	s.observe("ClearCache", EventInvalidation, len(keys), start, err)
+	return err
+}
+
+// clearIndexes delete the index keys of written objs, the write succeeded already, failures are logged only
+func (s *FullRedisCache[T, I]) clearIndexes(ctx context.Context, objs ...T) {
+	keys := s.indexKeys(objs...)
+	if err := s.delIndexes(ctx, keys); err != nil {
+		s.warn("scache: clear cache after write failed", err, "keys", keys)
+	}
 }
 
 func (s *FullRedisCache[T, I]) GetBy(index Index) (T, error) {
+	return s.GetByCtx(context.Background(), index)
+}
+
+func (s *FullRedisCache[T, I]) GetByCtx(ctx context.Context, index Index) (T, error) {
 	// fetch id from redis
 	redisKey := s.MakeCacheKey(index)
 	var r T
-	cachedId, err := s.redId.GetJson(redisKey)
+	start := time.Now()
+	cachedId, err := s.redId.GetJsonCtx(ctx, redisKey)
 	if err != nil && err != redis.Nil {
+		s.observe("GetBy", EventError, 1, start, err)
 		return r, err
 	}
 	if err == nil && IsNullID(cachedId) {
-		return r, nil
+		s.observe("GetBy", EventNullHit, 1, start, nil)
+		return r, ErrRecordNotFound
 	}
 	if err == nil {
-		s.red.Expires(redisKey)
-		return s.Get(cachedId)
+		s.observe("GetBy", EventHit, 1, start, nil)
+		s.touch(ctx, redisKey)
+		return s.GetCtx(ctx, cachedId)
 	}
+	s.observe("GetBy", EventMiss, 1, start, nil)
 	// search from db
-	r, err = s.db.GetBy(index)
+	start = time.Now()
+	r, err = s.db.GetByCtx(ctx, index)
 	if err != nil && err != ErrRecordNotFound {
+		s.observe("GetBy", EventDBLoad, 1, start, err)
 		return r, err
 	}
+	s.observe("GetBy", EventDBLoad, 1, start, nil)
+	start = time.Now()
 	if err == ErrRecordNotFound {
-		err = s.red.SetNull(redisKey)
+		err = s.red.SetNullCtx(ctx, redisKey)
+		s.observe("GetBy", EventSet, 1, start, err)
+		if err != nil {
+			s.warn("scache: fill cache failed", err, "key", redisKey)
+		}
 		return r, ErrRecordNotFound
 	}
 	// set id to redis
-	err = s.redId.SetJson(redisKey, r.GetID())
+	err = s.redId.SetJsonCtx(ctx, redisKey, r.GetID())
+	s.observe("GetBy", EventSet, 1, start, err)
 	return r, err
 }
 
 func (s *FullRedisCache[T, I]) ListBy(index Index, orderBys OrderBys) ([]T, error) {
+	return s.ListByCtx(context.Background(), index, orderBys)
+}
+
+func (s *FullRedisCache[T, I]) ListByCtx(ctx context.Context, index Index, orderBys OrderBys) ([]T, error) {
 	// fetch ids from redis
 	redisKey := s.MakeCacheKey(index)
+	if len(orderBys) > 0 {
+		redisKey += "/order/" + orderKey(orderBys)
+	}
 	var r []T
-	cachedIds, err := s.redIds.GetJson(redisKey)
+	start := time.Now()
+	cachedIds, err := s.redIds.GetJsonCtx(ctx, redisKey)
 	if err != nil && err != redis.Nil {
+		s.observe("ListBy", EventError, 1, start, err)
 		return nil, err
 	}
 	if err == nil {
-		s.red.Expires(redisKey)
-		return s.List(cachedIds...)
+		s.observe("ListBy", EventHit, 1, start, nil)
+		if len(orderBys) > 0 {
+			s.touchVariant(ctx, s.MakeCacheKey(index), redisKey)
+		} else {
+			s.touch(ctx, redisKey)
+		}
+		return s.ListCtx(ctx, cachedIds...)
 	}
+	s.observe("ListBy", EventMiss, 1, start, nil)
 	// search from db
-	r, err = s.db.ListBy(index, orderBys)
+	start = time.Now()
+	r, err = s.db.ListByCtx(ctx, index, orderBys)
+	s.observe("ListBy", EventDBLoad, len(r), start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -211,16 +412,73 @@ func (s *FullRedisCache[T, I]) ListBy(index Index, orderBys OrderBys) ([]T, erro
 		ids[i] = v.GetID()
 	}
 	// set ids to redis
-	err = s.redIds.SetJson(redisKey, ids)
+	start = time.Now()
+	err = s.redIds.SetJsonCtx(ctx, redisKey, ids)
+	if err == nil && len(orderBys) > 0 {
+		// ordered lists are deleted with the index key
+		err = s.registerVariant(ctx, s.MakeCacheKey(index), redisKey)
+	}
+	s.observe("ListBy", EventSet, 1, start, err)
 	return r, err
 }
 
+// registerVariant add key into the variants of indexKey
+func (s *FullRedisCache[T, I]) registerVariant(ctx context.Context, indexKey, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	return cacheError(s.redIds.store.SAdd(ctx, variantsKey(indexKey), s.redIds.ttl+s.redIds.jitter, key))
+}
+
+// touchVariant refresh the ttl of an ordered list, and of the variants of indexKey to outlive it
+func (s *FullRedisCache[T, I]) touchVariant(ctx context.Context, indexKey, key string) {
+	ttls := map[string]time.Duration{key: s.redIds.expiration(), variantsKey(indexKey): s.redIds.ttl + s.redIds.jitter}
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	if err := s.redIds.store.Expire(ctx, ttls); err != nil {
+		s.warn("scache: refresh ttl failed", cacheError(err), "keys", []string{key, variantsKey(indexKey)})
+	}
+}
+
+func (s *FullRedisCache[T, I]) CountBy(index Index) (int64, error) {
+	return s.CountByCtx(context.Background(), index)
+}
+
+// CountByCtx number of records of ListByCtx, all records are cached anyway
+func (s *FullRedisCache[T, I]) CountByCtx(ctx context.Context, index Index) (int64, error) {
+	r, err := s.ListByCtx(ctx, index, nil)
+	return int64(len(r)), err
+}
+
+func (s *FullRedisCache[T, I]) ListByPage(index Index, orderBys OrderBys, offset, limit int) ([]T, error) {
+	return s.ListByPageCtx(context.Background(), index, orderBys, offset, limit)
+}
+
+// ListByPageCtx page of ListByCtx, all records are cached anyway
+func (s *FullRedisCache[T, I]) ListByPageCtx(ctx context.Context, index Index, orderBys OrderBys, offset, limit int) ([]T, error) {
+	r, err := s.ListByCtx(ctx, index, orderBys)
+	if err != nil || offset < 0 || limit <= 0 || offset >= len(r) {
+		return nil, err
+	}
+	if offset+limit > len(r) {
+		limit = len(r) - offset
+	}
+	return r[offset : offset+limit], nil
+}
+
 // ListIn list objs by index field in values
 func (s *FullRedisCache[T, I]) ListByUniqueInts(field string, values []int64) ([]T, error) {
-	return nil, errors.New("not implemented, please use ListAll instead")
+	return s.ListByUniqueIntsCtx(context.Background(), field, values)
+}
+
+func (s *FullRedisCache[T, I]) ListByUniqueIntsCtx(ctx context.Context, field string, values []int64) ([]T, error) {
+	return nil, fmt.Errorf("%w: ListByUniqueInts, please use ListAll instead", ErrNotSupported)
 }
 
 // ListIn list objs by index field in values
 func (s *FullRedisCache[T, I]) ListByUniqueStrs(field string, values []string) ([]T, error) {
-	return nil, errors.New("not implemented, please use ListAll instead")
+	return s.ListByUniqueStrsCtx(context.Background(), field, values)
+}
+
+func (s *FullRedisCache[T, I]) ListByUniqueStrsCtx(ctx context.Context, field string, values []string) ([]T, error) {
+	return nil, fmt.Errorf("%w: ListByUniqueStrs, please use ListAll instead", ErrNotSupported)
 }
@@ -0,0 +1,67 @@
+package scache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/scache"
+	"github.com/daqiancode/scache/scachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFullRedisCacheListByOrders(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	db := scachetest.NewMemoryDB[member, int]("Id")
+	assert.Nil(t, db.Create(&member{Id: 1, Group: 1}))
+	assert.Nil(t, db.Create(&member{Id: 2, Group: 1}))
+	ca := scache.NewFullRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	index := scache.NewIndex("Group", 1)
+	indexKey := ca.MakeCacheKey(index)
+	asc := scache.NewOrderBys("Id", true)
+	desc := scache.NewOrderBys("Id", false)
+
+	rs, err := ca.ListBy(index, asc)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 2}, ids(rs))
+	rs, err = ca.ListBy(index, desc)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{2, 1}, ids(rs))
+	_, err = ca.ListBy(index, nil)
+	assert.Nil(t, err)
+	variants := []string{indexKey, indexKey + "/order/id.asc", indexKey + "/order/id.desc"}
+	for _, k := range variants {
+		assert.True(t, mr.Exists(k), k)
+	}
+	// each order is served from its own entry
+	rs, err = ca.ListBy(index, desc)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{2, 1}, ids(rs))
+
+	assert.Nil(t, ca.ClearCache(member{Id: 1, Group: 1}))
+	for _, k := range append(variants, indexKey+"/variants") {
+		assert.False(t, mr.Exists(k), k)
+	}
+}
+
+func TestFullRedisCacheListByOrderExpiry(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	db := scachetest.NewMemoryDB[member, int]("Id")
+	assert.Nil(t, db.Create(&member{Id: 1, Group: 1}))
+	assert.Nil(t, db.Create(&member{Id: 2, Group: 1}))
+	ca := scache.NewFullRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	index := scache.NewIndex("Group", 1)
+	asc := scache.NewOrderBys("Id", true)
+
+	_, err := ca.ListBy(index, asc)
+	assert.Nil(t, err)
+	// a hit keeps the list and its variants alive together
+	mr.FastForward(50 * time.Second)
+	_, err = ca.ListBy(index, asc)
+	assert.Nil(t, err)
+	mr.FastForward(20 * time.Second)
+	_, err = ca.Update(2, map[string]interface{}{"Group": 2})
+	assert.Nil(t, err)
+	rs, err := ca.ListBy(index, asc)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1}, ids(rs))
+}
@@ -0,0 +1,90 @@
+package scache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// GenerationKey redis key of the table generation, which is part of every cache key of the table
+func (s *RedisCache[T, I]) GenerationKey() string {
+	return s.prefix + "/" + s.table + "/generation"
+}
+
+// EnableGeneration include the table generation stored in redis in cache keys, so that InvalidateAll can abandon all entries of the table.
+// The generation is reloaded from redis when the loaded one is older than refresh, and before every write clears the cache.
+// Every instance must enable it.
+func (s *RedisCache[T, I]) EnableGeneration(refresh time.Duration) {
+	s.generationRefresh = refresh
+	atomic.StoreInt64(&s.generationSyncedAt, 0)
+}
+
+// syncGeneration reload the table generation from redis if it is stale
+func (s *RedisCache[T, I]) syncGeneration(ctx context.Context) {
+	s.loadGeneration(ctx, false)
+}
+
+// reloadGeneration reload the table generation from redis now.
+// Writes use it, deleting keys of an abandoned generation would leave the current entries stale
+func (s *RedisCache[T, I]) reloadGeneration(ctx context.Context) {
+	s.loadGeneration(ctx, true)
+}
+
+func (s *RedisCache[T, I]) loadGeneration(ctx context.Context, force bool) {
+	if s.generationRefresh <= 0 || s.cacheSkipped() {
+		return
+	}
+	now := time.Now().UnixNano()
+	if !force && now-atomic.LoadInt64(&s.generationSyncedAt) < int64(s.generationRefresh) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	var g int64
+	v, err := s.red.store.Get(ctx, s.GenerationKey())
+	if err == nil {
+		g, err = strconv.ParseInt(v, 10, 64)
+	}
+	if err != nil && err != redis.Nil {
+		// keep the last known generation, retry on next call
+		s.warn("scache: reload generation failed", err, "key", s.GenerationKey())
+		return
+	}
+	atomic.StoreInt64(&s.generationSyncedAt, now)
+	s.SetGeneration(g)
+}
+
+// InvalidateAll bump the table generation, all cached records and indexes of the table are abandoned and age out by ttl.
+// Use it after changing the table without the cache, eg. bulk sql migrations
+func (s *RedisCache[T, I]) InvalidateAll() error {
+	return s.InvalidateAllCtx(context.Background())
+}
+
+func (s *RedisCache[T, I]) InvalidateAllCtx(ctx context.Context) error {
+	if s.generationRefresh <= 0 {
+		return fmt.Errorf("%w: generation is not enabled, call EnableGeneration first", ErrNotSupported)
+	}
+	redCtx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	start := time.Now()
+	g, err := s.red.store.Incr(redCtx, s.GenerationKey())
+	err = cacheError(err)
+	s.observe("InvalidateAll", EventInvalidation, 0, start, err)
+	if err != nil {
+		return err
+	}
+	s.SetGeneration(g)
+	atomic.StoreInt64(&s.generationSyncedAt, time.Now().UnixNano())
+	if s.local != nil {
+		s.local.Purge()
+	}
+	if s.invalidator != nil {
+		// other instances flush local caches and reload the generation at once
+		return s.invalidator.PublishPurge(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package scache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/scache"
+	"github.com/daqiancode/scache/scachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerationWriteAfterRemoteInvalidateAll(t *testing.T) {
+	red, _ := scachetest.NewRedis(t)
+	db := scachetest.NewMemoryDB[member, int]("Id")
+	assert.Nil(t, db.Create(&member{Id: 1, Group: 1}))
+	a := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	a.EnableGeneration(time.Hour)
+	b := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	b.EnableGeneration(time.Hour)
+
+	// b loads the generation before a bumps it
+	_, err := b.Get(1)
+	assert.Nil(t, err)
+	assert.Nil(t, a.InvalidateAll())
+	r, err := a.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, member{Id: 1, Group: 1}, r)
+
+	// the write on b clears the entry of the current generation
+	_, err = b.Update(1, map[string]interface{}{"Group": 2})
+	assert.Nil(t, err)
+	r, err = a.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, member{Id: 1, Group: 2}, r)
+
+	assert.Nil(t, a.InvalidateAll())
+	_, err = a.Get(1)
+	assert.Nil(t, err)
+	_, err = db.Update(1, map[string]interface{}{"Group": 3})
+	assert.Nil(t, err)
+	assert.Nil(t, b.ClearCache(member{Id: 1, Group: 2}))
+	r, err = a.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, member{Id: 1, Group: 3}, r)
+}
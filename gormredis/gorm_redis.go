@@ -1,26 +1,79 @@
 package gormredis
 
 import (
+	"context"
+	"fmt"
 	"time"
 
 	"github.com/daqiancode/scache"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 )
 
-func NewGormRedis[T scache.Table[I], I scache.IDType](prefix, table, idField string, db *gorm.DB, red *redis.Client, ttl time.Duration) *scache.RedisCache[T, I] {
-	rc := scache.NewRedisCache[T, I](prefix, table, idField, &Gorm[T, I]{db: db, table: table, idField: idField}, red, ttl)
+// NewGormRedis empty idField means the id field declared by scache tags of T
+func NewGormRedis[T scache.Table[I], I scache.IDType](prefix, table, idField string, db *gorm.DB, red *redis.Client, ttl time.Duration, opts ...scache.Option) *scache.RedisCache[T, I] {
+	if idField == "" {
+		idField = scache.MetaOf[T]().IdField
+	}
+	rc := scache.NewRedisCache[T, I](prefix, table, idField, &Gorm[T, I]{db: db, table: table, idField: idField}, red, ttl, opts...)
 	return rc
 }
-func NewGormRedisFull[T scache.Table[I], I scache.IDType](prefix, table, idField string, db *gorm.DB, red *redis.Client, ttl time.Duration) scache.FullCache[T, I] {
-	rc := scache.NewFullRedisCache[T, I](prefix, table, idField, &Gorm[T, I]{db: db, table: table, idField: idField}, red, ttl)
+func NewGormRedisFull[T scache.Table[I], I scache.IDType](prefix, table, idField string, db *gorm.DB, red *redis.Client, ttl time.Duration, opts ...scache.Option) scache.FullCache[T, I] {
+	if idField == "" {
+		idField = scache.MetaOf[T]().IdField
+	}
+	rc := scache.NewFullRedisCache[T, I](prefix, table, idField, &Gorm[T, I]{db: db, table: table, idField: idField}, red, ttl, opts...)
 	return rc
 }
 
+// NewGormRedisAuto table name & id field are read from the gorm schema of T, ie. TableName() or the naming strategy of db, and the primary key
+func NewGormRedisAuto[T scache.Table[I], I scache.IDType](prefix string, db *gorm.DB, red *redis.Client, ttl time.Duration, opts ...scache.Option) (*scache.RedisCache[T, I], error) {
+	g, err := NewGorm[T, I](db)
+	if err != nil {
+		return nil, err
+	}
+	return scache.NewRedisCache[T, I](prefix, g.table, g.idField, g, red, ttl, opts...), nil
+}
+
+// NewGormRedisFullAuto table name & id field are read from the gorm schema of T like NewGormRedisAuto
+func NewGormRedisFullAuto[T scache.Table[I], I scache.IDType](prefix string, db *gorm.DB, red *redis.Client, ttl time.Duration, opts ...scache.Option) (scache.FullCache[T, I], error) {
+	g, err := NewGorm[T, I](db)
+	if err != nil {
+		return nil, err
+	}
+	return scache.NewFullRedisCache[T, I](prefix, g.table, g.idField, g, red, ttl, opts...), nil
+}
+
+// NewGorm gorm database of T, table name, primary key and column names are read from the gorm schema of T
+func NewGorm[T scache.Table[I], I scache.IDType](db *gorm.DB) (*Gorm[T, I], error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	sch := stmt.Schema
+	if len(sch.PrimaryFields) != 1 {
+		return nil, fmt.Errorf("%w: %s has %d primary keys, expect 1", scache.ErrNotSupported, sch.Name, len(sch.PrimaryFields))
+	}
+	return &Gorm[T, I]{db: db, table: sch.Table, idField: sch.PrimaryFields[0].Name, schema: sch}, nil
+}
+
 type Gorm[T scache.Table[I], I scache.IDType] struct {
 	db      *gorm.DB
 	table   string
 	idField string
+	// schema nil means column names are derived by the naming strategy
+	schema *schema.Schema
+}
+
+// column column name of field
+func (s *Gorm[T, I]) column(field string) string {
+	if s.schema != nil {
+		if f := s.schema.LookUpField(field); f != nil && f.DBName != "" {
+			return f.DBName
+		}
+	}
+	return s.db.NamingStrategy.ColumnName(s.table, field)
 }
 
 func (s *Gorm[T, I]) Close() error {
@@ -30,23 +83,32 @@ func (s *Gorm[T, I]) DB() *gorm.DB {
 	return s.db
 }
 func (s *Gorm[T, I]) Create(r *T) error {
-	if err := s.db.Create(r).Error; err != nil {
-		return err
+	return s.CreateCtx(context.Background(), r)
+}
+func (s *Gorm[T, I]) CreateCtx(ctx context.Context, r *T) error {
+	if err := s.db.WithContext(ctx).Create(r).Error; err != nil {
+		return scache.NewDBError("Create", s.table, err)
 	}
 	return nil
 }
 func (s *Gorm[T, I]) Save(r *T) error {
-	_, err := s.Get((*r).GetID())
+	return s.SaveCtx(context.Background(), r)
+}
+func (s *Gorm[T, I]) SaveCtx(ctx context.Context, r *T) error {
+	_, err := s.GetCtx(ctx, (*r).GetID())
 	if err != nil && err != scache.ErrRecordNotFound {
 		return err
 	}
 	if err == scache.ErrRecordNotFound {
-		return s.Create(r)
+		return s.CreateCtx(ctx, r)
 	}
-	return s.db.Save(r).Error
+	return scache.NewDBError("Save", s.table, s.db.WithContext(ctx).Save(r).Error)
 }
 func (s *Gorm[T, I]) Update(id I, values interface{}) (int64, error) {
-	old, err := s.Get(id)
+	return s.UpdateCtx(context.Background(), id, values)
+}
+func (s *Gorm[T, I]) UpdateCtx(ctx context.Context, id I, values interface{}) (int64, error) {
+	old, err := s.GetCtx(ctx, id)
 	if err != nil {
 		return 0, err
 	}
@@ -56,89 +118,146 @@ func (s *Gorm[T, I]) Update(id I, values interface{}) (int64, error) {
 			return 0, nil
 		}
 	} else {
-		rs = s.db.Model(&old).Updates(values)
+		rs = s.db.WithContext(ctx).Model(&old).Updates(values)
 	}
 	if rs.Error != nil {
-		return 0, rs.Error
+		return 0, scache.NewDBError("Update", s.table, rs.Error)
 	}
 	return rs.RowsAffected, nil
 }
 func (s *Gorm[T, I]) Delete(ids ...I) (int64, error) {
-	rs := s.db.Delete(new(T), ids)
+	return s.DeleteCtx(context.Background(), ids...)
+}
+func (s *Gorm[T, I]) DeleteCtx(ctx context.Context, ids ...I) (int64, error) {
+	rs := s.db.WithContext(ctx).Delete(new(T), ids)
 	if rs.Error != nil {
-		return 0, rs.Error
+		return 0, scache.NewDBError("Delete", s.table, rs.Error)
 	}
 	return rs.RowsAffected, nil
 }
 func (s *Gorm[T, I]) Get(id I) (T, error) {
+	return s.GetCtx(context.Background(), id)
+}
+func (s *Gorm[T, I]) GetCtx(ctx context.Context, id I) (T, error) {
 	var r T
-	if err := s.db.Where(map[string]interface{}{s.idField: id}).First(&r).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where(map[string]interface{}{s.column(s.idField): id}).First(&r).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return r, scache.ErrRecordNotFound
 		}
-		return r, err
+		return r, scache.NewDBError("Get", s.table, err)
 	}
 	return r, nil
 }
 func (s *Gorm[T, I]) GetBy(index scache.Index) (T, error) {
+	return s.GetByCtx(context.Background(), index)
+}
+func (s *Gorm[T, I]) GetByCtx(ctx context.Context, index scache.Index) (T, error) {
 	var r T
 	index1 := make(scache.Index, len(index))
 	for k, v := range index {
-		index1[s.db.NamingStrategy.ColumnName(s.table, k)] = v
+		index1[s.column(k)] = v
 	}
-	if err := s.db.Where(map[string]interface{}(index1)).First(&r).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where(map[string]interface{}(index1)).First(&r).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return r, scache.ErrRecordNotFound
 		}
-		return r, err
+		return r, scache.NewDBError("GetBy", s.table, err)
 	}
 	return r, nil
 }
 func (s *Gorm[T, I]) List(ids ...I) ([]T, error) {
+	return s.ListCtx(context.Background(), ids...)
+}
+func (s *Gorm[T, I]) ListCtx(ctx context.Context, ids ...I) ([]T, error) {
 	var r []T
-	err := s.db.Find(&r, ids).Error
-	return r, err
+	err := s.db.WithContext(ctx).Find(&r, ids).Error
+	return r, scache.NewDBError("List", s.table, err)
 }
 func (s *Gorm[T, I]) ListBy(index scache.Index, initOrders scache.OrderBys) ([]T, error) {
+	return s.ListByCtx(context.Background(), index, initOrders)
+}
+func (s *Gorm[T, I]) ListByCtx(ctx context.Context, index scache.Index, initOrders scache.OrderBys) ([]T, error) {
 	var r []T
 	index1 := make(scache.Index, len(index))
 	for k, v := range index {
-		index1[s.db.NamingStrategy.ColumnName(s.table, k)] = v
+		index1[s.column(k)] = v
 	}
-	for i, v := range initOrders {
-		initOrders[i].Field = s.db.NamingStrategy.ColumnName(s.table, v.Field)
+	if err := s.db.WithContext(ctx).Where(map[string]interface{}(index1)).Order(s.columnOrders(initOrders).String()).Find(&r).Error; err != nil {
+		return nil, scache.NewDBError("ListBy", s.table, err)
 	}
-
-	if err := s.db.Where(map[string]interface{}(index1)).Order(initOrders.String()).Find(&r).Error; err != nil {
-		return nil, err
+	return r, nil
+}
+func (s *Gorm[T, I]) CountBy(index scache.Index) (int64, error) {
+	return s.CountByCtx(context.Background(), index)
+}
+func (s *Gorm[T, I]) CountByCtx(ctx context.Context, index scache.Index) (int64, error) {
+	var r int64
+	index1 := make(scache.Index, len(index))
+	for k, v := range index {
+		index1[s.column(k)] = v
+	}
+	if err := s.db.WithContext(ctx).Model(new(T)).Where(map[string]interface{}(index1)).Count(&r).Error; err != nil {
+		return 0, scache.NewDBError("CountBy", s.table, err)
 	}
 	return r, nil
 }
+func (s *Gorm[T, I]) ListByPage(index scache.Index, orderBys scache.OrderBys, offset, limit int) ([]T, error) {
+	return s.ListByPageCtx(context.Background(), index, orderBys, offset, limit)
+}
+func (s *Gorm[T, I]) ListByPageCtx(ctx context.Context, index scache.Index, orderBys scache.OrderBys, offset, limit int) ([]T, error) {
+	var r []T
+	index1 := make(scache.Index, len(index))
+	for k, v := range index {
+		index1[s.column(k)] = v
+	}
+	if err := s.db.WithContext(ctx).Where(map[string]interface{}(index1)).Order(s.columnOrders(orderBys).String()).Offset(offset).Limit(limit).Find(&r).Error; err != nil {
+		return nil, scache.NewDBError("ListByPage", s.table, err)
+	}
+	return r, nil
+}
+
+// columnOrders orderBys with column names, orderBys is not modified
+func (s *Gorm[T, I]) columnOrders(orderBys scache.OrderBys) scache.OrderBys {
+	r := make(scache.OrderBys, len(orderBys))
+	for i, v := range orderBys {
+		r[i] = scache.OrderBy{Field: s.column(v.Field), Asc: v.Asc}
+	}
+	return r
+}
 
 func (s *Gorm[T, I]) ListAll() ([]T, error) {
+	return s.ListAllCtx(context.Background())
+}
+func (s *Gorm[T, I]) ListAllCtx(ctx context.Context) ([]T, error) {
 	var r []T
-	if err := s.db.Find(&r).Error; err != nil {
-		return nil, err
+	if err := s.db.WithContext(ctx).Find(&r).Error; err != nil {
+		return nil, scache.NewDBError("ListAll", s.table, err)
 	}
 	return r, nil
 }
 
 // ListIn list objs by index field in values
 func (s *Gorm[T, I]) ListByUniqueInts(field string, values []int64) ([]T, error) {
-	dbField := s.db.NamingStrategy.ColumnName(s.table, field)
+	return s.ListByUniqueIntsCtx(context.Background(), field, values)
+}
+func (s *Gorm[T, I]) ListByUniqueIntsCtx(ctx context.Context, field string, values []int64) ([]T, error) {
+	dbField := s.column(field)
 	var r []T
-	if err := s.db.Where(dbField+" in ?", values).Find(&r).Error; err != nil {
-		return nil, err
+	if err := s.db.WithContext(ctx).Where(dbField+" in ?", values).Find(&r).Error; err != nil {
+		return nil, scache.NewDBError("ListByUniqueInts", s.table, err)
 	}
 	return r, nil
 }
 
 // ListIn list objs by index field in values
 func (s *Gorm[T, I]) ListByUniqueStrs(field string, values []string) ([]T, error) {
-	dbField := s.db.NamingStrategy.ColumnName(s.table, field)
+	return s.ListByUniqueStrsCtx(context.Background(), field, values)
+}
+func (s *Gorm[T, I]) ListByUniqueStrsCtx(ctx context.Context, field string, values []string) ([]T, error) {
+	dbField := s.column(field)
 	var r []T
-	if err := s.db.Where(dbField+" in ?", values).Find(&r).Error; err != nil {
-		return nil, err
+	if err := s.db.WithContext(ctx).Where(dbField+" in ?", values).Find(&r).Error; err != nil {
+		return nil, scache.NewDBError("ListByUniqueStrs", s.table, err)
 	}
 	return r, nil
 }
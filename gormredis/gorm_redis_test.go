@@ -14,6 +14,7 @@ import (
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
 )
 
 func GetDBClient() *gorm.DB {
@@ -152,3 +153,79 @@ func TestUpdate(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, int64(1), rowsAffected)
 }
+
+type autoOrder struct {
+	OrderNo string `gorm:"primaryKey;column:no"`
+	Uid     uint64 `gorm:"column:user_id"`
+}
+
+func (s autoOrder) GetID() string               { return s.OrderNo }
+func (s autoOrder) ListIndexes() scache.Indexes { return nil }
+func (autoOrder) TableName() string             { return "orders" }
+
+func TestNewGormRedisAuto(t *testing.T) {
+	// no connection is made
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "root:123456@tcp(localhost:3306)/test", SkipInitializeWithVersion: true}), &gorm.Config{DisableAutomaticPing: true})
+	assert.Nil(t, err)
+	ca, err := gormredis.NewGormRedisAuto[Commodity, string]("app", db, getRedisClient(), time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, "commodities", ca.GetTableName())
+	assert.Equal(t, "Id", ca.GetIdField())
+	orders, err := gormredis.NewGormRedisAuto[autoOrder, string]("app", db, getRedisClient(), time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, "orders", orders.GetTableName())
+	assert.Equal(t, "OrderNo", orders.GetIdField())
+}
+
+type compositeOrder struct {
+	Shop    string `gorm:"primaryKey"`
+	OrderNo string `gorm:"primaryKey"`
+}
+
+func (s compositeOrder) GetID() string               { return s.OrderNo }
+func (s compositeOrder) ListIndexes() scache.Indexes { return nil }
+
+// sqlRecorder keeps the sql logged by gorm
+type sqlRecorder struct {
+	sqls []string
+}
+
+func (s *sqlRecorder) Printf(format string, args ...interface{}) {
+	s.sqls = append(s.sqls, fmt.Sprint(args[len(args)-1]))
+}
+
+func TestNewGorm(t *testing.T) {
+	rec := &sqlRecorder{}
+	// no connection is made, queries are only built
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "root:123456@tcp(localhost:3306)/test", SkipInitializeWithVersion: true}), &gorm.Config{
+		DisableAutomaticPing: true,
+		DryRun:               true,
+		NamingStrategy:       schema.NamingStrategy{TablePrefix: "t_", SingularTable: true},
+		Logger:               logger.New(rec, logger.Config{LogLevel: logger.Info}),
+	})
+	assert.Nil(t, err)
+
+	// naming strategy
+	ca, err := gormredis.NewGormRedisAuto[Commodity, string]("app", db, getRedisClient(), time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, "t_commodity", ca.GetTableName())
+	assert.Equal(t, "Id", ca.GetIdField())
+
+	// TableName(), primaryKey & column tags
+	g, err := gormredis.NewGorm[autoOrder, string](db)
+	assert.Nil(t, err)
+	_, err = g.GetBy(scache.NewIndex("Uid", 1))
+	assert.Nil(t, err)
+	_, err = g.ListBy(scache.NewIndex("Uid", 1), scache.NewOrderBys("OrderNo", false))
+	assert.Nil(t, err)
+	_, err = g.Get("a")
+	assert.Nil(t, err)
+	if assert.Len(t, rec.sqls, 3) {
+		assert.Contains(t, rec.sqls[0], "FROM `orders` WHERE `user_id` = 1")
+		assert.Contains(t, rec.sqls[1], "FROM `orders` WHERE `user_id` = 1 ORDER BY no DESC")
+		assert.Contains(t, rec.sqls[2], "WHERE `no` = 'a'")
+	}
+
+	_, err = gormredis.NewGorm[compositeOrder, string](db)
+	assert.ErrorIs(t, err, scache.ErrNotSupported)
+}
@@ -0,0 +1,177 @@
+package scache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// InvalidationReconnectDelay wait before resubscribing after the invalidation subscription failed
+var InvalidationReconnectDelay = time.Second
+
+// purgeMessage ask subscribers to flush their whole local caches
+const purgeMessage = "*"
+
+// Invalidator keep local caches of all instances consistent: cleared keys are published on a redis channel,
+// every instance subscribes the channel and evicts the published keys from its local cache
+type Invalidator struct {
+	client  *redis.Client
+	channel string
+	// mu guards local & logger, which may be changed while subscribing
+	mu     sync.RWMutex
+	local  LocalCache
+	logger Logger
+	// purgeHook called whenever the local cache is flushed
+	purgeHook func()
+	cancel    context.CancelFunc
+	done      chan struct{}
+	once      sync.Once
+}
+
+func NewInvalidator(client *redis.Client, channel string, local LocalCache) *Invalidator {
+	return &Invalidator{
+		client:  client,
+		channel: channel,
+		local:   local,
+	}
+}
+
+// SetPurgeHook set a function called whenever the local cache is flushed, call it before Start
+func (s *Invalidator) SetPurgeHook(hook func()) {
+	s.purgeHook = hook
+}
+
+// SetLogger report subscription failures and bad messages
+func (s *Invalidator) SetLogger(logger Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = logger
+}
+
+// SetLocalCache change the local cache evicted by messages, nil to evict nothing
+func (s *Invalidator) SetLocalCache(local LocalCache) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.local = local
+}
+
+// state local cache & logger of the moment
+func (s *Invalidator) state() (LocalCache, Logger) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.local, s.logger
+}
+
+// Start subscribe the channel in background until Close
+func (s *Invalidator) Start() {
+	s.once.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.cancel = cancel
+		s.done = make(chan struct{})
+		go s.run(ctx)
+	})
+}
+
+// Close stop subscribing
+func (s *Invalidator) Close() error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// Publish send keys to all subscribers
+func (s *Invalidator) Publish(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	payload, err := json.MarshalToString(keys)
+	if err != nil {
+		return err
+	}
+	return s.publish(ctx, payload)
+}
+
+// PublishPurge ask all subscribers to flush their local caches
+func (s *Invalidator) PublishPurge(ctx context.Context) error {
+	return s.publish(ctx, purgeMessage)
+}
+
+func (s *Invalidator) publish(ctx context.Context, payload string) error {
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	return cacheError(s.client.Publish(ctx, s.channel, payload).Err())
+}
+
+func (s *Invalidator) run(ctx context.Context) {
+	defer close(s.done)
+	for {
+		pubsub := s.client.Subscribe(ctx, s.channel)
+		// Receive doesn't return on cancellation, closing pubsub stops it
+		stop := context.AfterFunc(ctx, func() { pubsub.Close() })
+		err := s.listen(ctx, pubsub)
+		stop()
+		pubsub.Close()
+		if ctx.Err() != nil {
+			return
+		}
+		s.warn("scache: invalidation subscription failed, resubscribing", err)
+		// messages may be lost while disconnected
+		s.purge()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(InvalidationReconnectDelay):
+		}
+	}
+}
+
+// listen receive messages until an error occurs
+func (s *Invalidator) listen(ctx context.Context, pubsub *redis.PubSub) error {
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			return err
+		}
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			// (re)subscribed, messages published before are unknown
+			if m.Kind == "subscribe" {
+				s.purge()
+			}
+		case *redis.Message:
+			if m.Payload == purgeMessage {
+				s.purge()
+				continue
+			}
+			var keys []string
+			if err := json.UnmarshalFromString(m.Payload, &keys); err != nil {
+				s.warn("scache: bad invalidation message, local cache purged", err)
+				s.purge()
+				continue
+			}
+			if local, _ := s.state(); local != nil {
+				local.Del(keys...)
+			}
+		}
+	}
+}
+
+func (s *Invalidator) warn(msg string, err error) {
+	if _, logger := s.state(); logger != nil {
+		logger.Warn(msg, "channel", s.channel, "error", err)
+	}
+}
+
+func (s *Invalidator) purge() {
+	if local, _ := s.state(); local != nil {
+		local.Purge()
+	}
+	if s.purgeHook != nil {
+		s.purgeHook()
+	}
+}
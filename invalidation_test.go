@@ -0,0 +1,90 @@
+package scache_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/daqiancode/scache"
+	"github.com/daqiancode/scache/scachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func eventually(t *testing.T, cond func() bool, msg string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func waitSubscribed(t *testing.T, mr *miniredis.Miniredis, channel string) {
+	eventually(t, func() bool { return mr.PubSubNumSub(channel)[channel] > 0 }, "not subscribed")
+}
+
+func TestInvalidation(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	db := scachetest.NewMemoryDB[member, int]("Id")
+	assert.Nil(t, db.Create(&member{Id: 1, Group: 1}))
+	a := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	a.EnableInvalidation()
+	defer a.Close()
+	b := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	// enabled before the local cache & logger are set
+	b.EnableInvalidation()
+	defer b.Close()
+	local := scache.NewLRUCache(100, time.Minute)
+	b.SetLocalCache(local)
+	logger := &recordLogger{}
+	b.SetLogger(logger)
+	waitSubscribed(t, mr, b.InvalidationChannel())
+
+	_, err := b.Get(1)
+	assert.Nil(t, err)
+	key := b.MakeCacheKey(scache.NewIndex("Id", 1))
+	_, ok := local.Get(key)
+	assert.True(t, ok)
+	// a write on another instance evicts the local entry
+	_, err = a.Update(1, map[string]interface{}{"Group": 2})
+	assert.Nil(t, err)
+	eventually(t, func() bool { _, ok := local.Get(key); return !ok }, "local entry not evicted")
+	r, err := b.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, member{Id: 1, Group: 2}, r)
+
+	// bad messages purge the local cache and are logged
+	assert.Nil(t, red.Publish(context.Background(), b.InvalidationChannel(), "{").Err())
+	eventually(t, func() bool { return local.Len() == 0 && logger.Len() > 0 }, "bad message not handled")
+}
+
+func TestInvalidationResubscribe(t *testing.T) {
+	delay := scache.InvalidationReconnectDelay
+	scache.InvalidationReconnectDelay = 10 * time.Millisecond
+	defer func() { scache.InvalidationReconnectDelay = delay }()
+	red, mr := scachetest.NewRedis(t)
+	local := scache.NewLRUCache(100, time.Minute)
+	inv := scache.NewInvalidator(red, "test/invalidation", local)
+	var purges int64
+	inv.SetPurgeHook(func() { atomic.AddInt64(&purges, 1) })
+	inv.Start()
+	defer inv.Close()
+	waitSubscribed(t, mr, "test/invalidation")
+	eventually(t, func() bool { return atomic.LoadInt64(&purges) == 1 }, "not purged on subscribe")
+
+	assert.Nil(t, inv.Publish(context.Background(), "a"))
+	local.Set("b", 1)
+	// messages may be lost while disconnected, the local cache is flushed on reconnect
+	mr.Close()
+	eventually(t, func() bool { return atomic.LoadInt64(&purges) >= 2 }, "not purged on disconnect")
+	local.Set("b", 1)
+	n := atomic.LoadInt64(&purges)
+	assert.Nil(t, mr.Restart())
+	eventually(t, func() bool { return atomic.LoadInt64(&purges) > n }, "not purged on resubscribe")
+	_, ok := local.Get("b")
+	assert.False(t, ok)
+}
@@ -0,0 +1,193 @@
+package scache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LeaseOptions memcache style leases, a reader missing a key fills it only if its lease is not revoked by ClearCache meanwhile
+type LeaseOptions struct {
+	// TTL lifetime of a lease, longer than a db load, DefaultLeaseTTL if <=0
+	TTL time.Duration
+	// Wait sleep before re-reading the cache when another reader holds the lease, DefaultLeaseWait if <=0
+	Wait time.Duration
+	// Retries max re-reads before loading from db without filling the cache
+	Retries int
+}
+
+// EnableLease a missing reader acquires a lease before loading from db and fills the cache only if the lease is still valid,
+// so that a reader loading the old row before a write can't fill it after the write cleared the cache.
+// Other readers missing the same key wait for the lease holder and re-read the cache instead of querying db.
+// Stale entries refreshed in background by EnableStaleWhileRevalidate are filled under a lease too.
+// Leases need a redis store, other stores fill unconditionally
+func (s *RedisCache[T, I]) EnableLease(opts LeaseOptions) {
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultLeaseTTL
+	}
+	if opts.Wait <= 0 {
+		opts.Wait = DefaultLeaseWait
+	}
+	s.leaseOpts = &opts
+}
+
+var (
+	DefaultLeaseTTL  = 3 * time.Second
+	DefaultLeaseWait = 50 * time.Millisecond
+)
+
+// LeaseKey redis key of the lease of key
+func LeaseKey(key string) string {
+	return key + "/lease"
+}
+
+// lease right to fill a missed key, nil lease means leases are disabled and the key is filled unconditionally
+type lease struct {
+	key string
+	// token empty token means the lease is not acquired, don't fill
+	token string
+}
+
+const (
+	leaseAcquired = 0
+	leaseFilled   = 1
+	leaseHeld     = 2
+)
+
+// KEYS[1] cache key, KEYS[2] lease key, ARGV[1] token, ARGV[2] lease ttl in milliseconds
+var acquireLeaseScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 1
+end
+if redis.call('SET', KEYS[2], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return 0
+end
+return 2
+`)
+
+// KEYS[1] cache key, KEYS[2] lease key, ARGV[1] token, ARGV[2] value, ARGV[3] ttl in milliseconds
+var fillLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[2]) == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+	redis.call('DEL', KEYS[2])
+	return 1
+end
+return 0
+`)
+
+func newLeaseToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("scache: generate lease token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// acquireLease acquire the lease of a missed key, filled=true means the key was filled by another reader meanwhile
+func (s *RedisCache[T, I]) acquireLease(ctx context.Context, key string) (l *lease, filled bool, err error) {
+	opts := s.leaseOpts
+	client := s.client()
+	if opts == nil || client == nil {
+		return nil, false, nil
+	}
+	l = &lease{key: LeaseKey(key)}
+	token, err := newLeaseToken()
+	if err != nil {
+		return nil, false, err
+	}
+	for attempt := 0; ; attempt++ {
+		redCtx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+		state, err := acquireLeaseScript.Run(redCtx, client, []string{key, l.key}, token, opts.TTL.Milliseconds()).Int()
+		cancel()
+		if err != nil {
+			return nil, false, cacheError(err)
+		}
+		switch state {
+		case leaseAcquired:
+			l.token = token
+			return l, false, nil
+		case leaseFilled:
+			return l, true, nil
+		}
+		if attempt >= opts.Retries {
+			return l, false, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-time.After(opts.Wait):
+		}
+	}
+}
+
+// refreshLease acquire the lease of a stale key without waiting, the lease is not acquired if another reader holds it.
+// nil lease means leases are disabled
+func (s *RedisCache[T, I]) refreshLease(ctx context.Context, key string) (*lease, error) {
+	opts := s.leaseOpts
+	client := s.client()
+	if opts == nil || client == nil {
+		return nil, nil
+	}
+	token, err := newLeaseToken()
+	if err != nil {
+		return nil, err
+	}
+	l := &lease{key: LeaseKey(key)}
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	ok, err := client.SetNX(ctx, l.key, token, opts.TTL).Result()
+	if err != nil {
+		return nil, cacheError(err)
+	}
+	if ok {
+		l.token = token
+	}
+	return l, nil
+}
+
+// setJsonLeased fill key with obj if the lease is still valid
+func (s *RedisJson[T]) setJsonLeased(ctx context.Context, key string, obj T, l *lease) error {
+	if l == nil {
+		return s.SetJsonCtx(ctx, key, obj)
+	}
+	y, err := s.serializer.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return s.fillLeased(ctx, key, y, obj, l)
+}
+
+// setNullLeased fill key with null if the lease is still valid
+func (s *RedisJson[T]) setNullLeased(ctx context.Context, key string, l *lease) error {
+	if l == nil {
+		return s.SetNullCtx(ctx, key)
+	}
+	var null T
+	return s.fillLeased(ctx, key, nullValue, null, l)
+}
+
+func (s *RedisJson[T]) fillLeased(ctx context.Context, key, payload string, obj T, l *lease) error {
+	if l.token == "" {
+		return nil
+	}
+	ttl := s.expiration()
+	if payload == nullValue {
+		ttl = s.nullExpiration()
+	}
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	ok, err := fillLeaseScript.Run(ctx, redisClient(s.store), []string{key, l.key}, l.token, s.encode(payload), ttl.Milliseconds()).Int()
+	if err != nil {
+		return cacheError(err)
+	}
+	if ok == 1 && payload == nullValue {
+		s.setLocal(key, localNull{})
+	} else if ok == 1 {
+		s.setLocal(key, obj)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package scache_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/scache"
+	"github.com/daqiancode/scache/scachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaseRevokedByClearCache(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	db := &gatedDB{MemoryDB: scachetest.NewMemoryDB[member, int]("Id"), gate: make(chan struct{})}
+	assert.Nil(t, db.MemoryDB.Create(&member{Id: 1, Group: 1}))
+	ca := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	// non-positive ttl falls back to the default
+	ca.EnableLease(scache.LeaseOptions{})
+	key := ca.MakeCacheKey(scache.NewIndex("Id", 1))
+
+	done := make(chan error)
+	go func() {
+		_, err := ca.Get(1)
+		done <- err
+	}()
+	for atomic.LoadInt64(&db.calls) < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, scache.DefaultLeaseTTL, mr.TTL(scache.LeaseKey(key)))
+	// a write while the reader loads revokes its lease, the loaded row is not filled
+	assert.Nil(t, ca.ClearCache(member{Id: 1, Group: 1}))
+	assert.False(t, mr.Exists(scache.LeaseKey(key)))
+	close(db.gate)
+	assert.Nil(t, <-done)
+	assert.False(t, mr.Exists(key))
+
+	_, err := ca.Get(1)
+	assert.Nil(t, err)
+	assert.True(t, mr.Exists(key))
+	assert.False(t, mr.Exists(scache.LeaseKey(key)))
+}
+
+func TestLeaseRevalidate(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	db := scachetest.NewMemoryDB[member, int]("Id")
+	assert.Nil(t, db.Create(&member{Id: 1, Group: 1}))
+	ca := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	ca.EnableLease(scache.LeaseOptions{TTL: time.Second})
+	ca.EnableStaleWhileRevalidate(10 * time.Millisecond)
+	key := ca.MakeCacheKey(scache.NewIndex("Id", 1))
+	_, err := ca.Get(1)
+	assert.Nil(t, err)
+	_, err = db.Update(1, map[string]interface{}{"Group": 2})
+	assert.Nil(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	// the refresh is skipped while another reader holds the lease
+	assert.Nil(t, mr.Set(scache.LeaseKey(key), "other"))
+	r, err := ca.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, r.Group)
+	time.Sleep(50 * time.Millisecond)
+	r, err = ca.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, r.Group)
+	v, _ := mr.Get(scache.LeaseKey(key))
+	assert.Equal(t, "other", v)
+
+	mr.Del(scache.LeaseKey(key))
+	eventually(t, func() bool {
+		r, err := ca.Get(1)
+		return err == nil && r.Group == 2
+	}, "stale entry not refreshed")
+	assert.False(t, mr.Exists(scache.LeaseKey(key)))
+}
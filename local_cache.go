@@ -0,0 +1,106 @@
+package scache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LocalCache in-process cache in front of redis, values are decoded objects shared by all readers, don't modify them
+type LocalCache interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{})
+	Del(keys ...string)
+	// Purge remove all entries
+	Purge()
+}
+
+type lruEntry struct {
+	key      string
+	value    interface{}
+	expireAt time.Time
+}
+
+// LRUCache bounded LocalCache, least recently used entries are evicted when size is exceeded, entries expire after ttl
+type LRUCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+// NewLRUCache size: max entries, ttl: lifetime of each entry, ttl<=0 means entries never expire
+func NewLRUCache(size int, ttl time.Duration) *LRUCache {
+	return &LRUCache{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (s *LRUCache) Get(key string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*lruEntry)
+	if s.ttl > 0 && time.Now().After(entry.expireAt) {
+		s.remove(e)
+		return nil, false
+	}
+	s.ll.MoveToFront(e)
+	return entry.value, true
+}
+
+func (s *LRUCache) Set(key string, value interface{}) {
+	if s.size <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expireAt := time.Now().Add(s.ttl)
+	if e, ok := s.items[key]; ok {
+		entry := e.Value.(*lruEntry)
+		entry.value = value
+		entry.expireAt = expireAt
+		s.ll.MoveToFront(e)
+		return
+	}
+	s.items[key] = s.ll.PushFront(&lruEntry{key: key, value: value, expireAt: expireAt})
+	for s.ll.Len() > s.size {
+		s.remove(s.ll.Back())
+	}
+}
+
+func (s *LRUCache) Del(keys ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		if e, ok := s.items[key]; ok {
+			s.remove(e)
+		}
+	}
+}
+
+func (s *LRUCache) Purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ll.Init()
+	s.items = make(map[string]*list.Element)
+}
+
+// Len number of entries, including expired ones not yet evicted
+func (s *LRUCache) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
+
+func (s *LRUCache) remove(e *list.Element) {
+	s.ll.Remove(e)
+	delete(s.items, e.Value.(*lruEntry).key)
+}
@@ -0,0 +1,100 @@
+package scache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/scache"
+	"github.com/daqiancode/scache/scachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUCache(t *testing.T) {
+	c := scache.NewLRUCache(2, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+	// b is the least recently used one
+	c.Set("c", 3)
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 2, c.Len())
+
+	c.Del("a")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	c.Purge()
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestLRUCacheTTL(t *testing.T) {
+	c := scache.NewLRUCache(10, 10*time.Millisecond)
+	c.Set("a", 1)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+	time.Sleep(20 * time.Millisecond)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestRedisCacheLocalCache(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	db := scachetest.NewMemoryDB[member, int]("Id")
+	assert.Nil(t, db.Create(&member{Id: 1, Group: 1}))
+	newCache := func() (*scache.RedisCache[member, int], *scache.LRUCache) {
+		ca := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+		local := scache.NewLRUCache(100, time.Minute)
+		ca.SetLocalCache(local)
+		return ca, local
+	}
+	ca, local := newCache()
+	key := ca.MakeCacheKey(scache.NewIndex("Id", 1))
+
+	// filled on db load
+	r, err := ca.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, member{Id: 1, Group: 1}, r)
+	_, ok := local.Get(key)
+	assert.True(t, ok)
+
+	// filled on redis hit
+	other, otherLocal := newCache()
+	reads := db.Reads()
+	_, err = other.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, reads, db.Reads())
+	_, ok = otherLocal.Get(key)
+	assert.True(t, ok)
+
+	// served locally without redis
+	mr.FlushAll()
+	r, err = ca.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, member{Id: 1, Group: 1}, r)
+	assert.Equal(t, reads, db.Reads())
+
+	// null entries are kept as not found
+	for i := 0; i < 2; i++ {
+		r, err = ca.Get(100)
+		assert.ErrorIs(t, err, scache.ErrRecordNotFound)
+		assert.Equal(t, member{}, r)
+	}
+	assert.Equal(t, reads+1, db.Reads())
+	mr.FlushAll()
+	_, err = ca.Get(100)
+	assert.ErrorIs(t, err, scache.ErrRecordNotFound, "null served locally")
+	assert.Equal(t, reads+1, db.Reads())
+
+	// evicted by ClearCache
+	assert.Nil(t, ca.ClearCache(member{Id: 1, Group: 1}))
+	_, ok = local.Get(key)
+	assert.False(t, ok)
+	_, err = ca.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, reads+2, db.Reads())
+}
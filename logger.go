@@ -0,0 +1,52 @@
+package scache
+
+import (
+	"log/slog"
+)
+
+// Logger report errors scache can't return to callers, eg. failed cache deletions after writes, failed ttl refreshes and undecodable entries.
+// *slog.Logger implements it
+type Logger interface {
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// NewSlogLogger log with l, nil means slog.Default()
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		return slog.Default()
+	}
+	return l
+}
+
+// SetLogger report swallowed errors to logger, nil to disable, which is the default
+func (s *CacheBase[T, I]) SetLogger(logger Logger) {
+	s.logger = logger
+}
+func (s *CacheBase[T, I]) GetLogger() Logger {
+	return s.logger
+}
+
+// warn log a redis failure which degrades the cache without failing the call
+func (s *CacheBase[T, I]) warn(msg string, err error, args ...any) {
+	if s.logger == nil {
+		return
+	}
+	s.logger.Warn(msg, append([]any{"table", s.table, "error", err}, args...)...)
+}
+
+// SetLogger report undecodable entries skipped by HGetAllJson and HMGetJson, nil to disable
+func (s *RedisJson[T]) SetLogger(logger Logger) {
+	s.logger = logger
+}
+func (s *RedisJson[T]) GetLogger() Logger {
+	return s.logger
+}
+
+// logDecodeError log an entry which can't be decoded
+func (s *RedisJson[T]) logDecodeError(key, field string, err error) {
+	if s.logger == nil {
+		return
+	}
+	s.logger.Error("scache: decode cached entry failed", "key", key, "field", field, "error", err)
+}
@@ -0,0 +1,169 @@
+package scache_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/scache"
+	"github.com/daqiancode/scache/scachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordLogger keeps the messages logged with their args
+type recordLogger struct {
+	mu   sync.Mutex
+	msgs []string
+	args [][]any
+}
+
+func (s *recordLogger) Warn(msg string, args ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.msgs = append(s.msgs, msg)
+	s.args = append(s.args, args)
+}
+
+func (s *recordLogger) Error(msg string, args ...any) {
+	s.Warn(msg, args...)
+}
+
+func (s *recordLogger) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.msgs)
+}
+
+// attrs args of the first message msg as a map, nil if msg is not logged
+func (s *recordLogger) attrs(msg string) map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, v := range s.msgs {
+		if v != msg {
+			continue
+		}
+		r := make(map[string]any)
+		for j := 0; j+1 < len(s.args[i]); j += 2 {
+			r[s.args[i][j].(string)] = s.args[i][j+1]
+		}
+		return r
+	}
+	return nil
+}
+
+var errStore = errors.New("store failed")
+
+// failingStore fails the operations in fail
+type failingStore struct {
+	*scache.MemoryStore
+	fail map[string]bool
+}
+
+func (s *failingStore) MSet(ctx context.Context, entries ...scache.Entry) error {
+	if s.fail["MSet"] {
+		return errStore
+	}
+	return s.MemoryStore.MSet(ctx, entries...)
+}
+
+func (s *failingStore) Del(ctx context.Context, keys ...string) error {
+	if s.fail["Del"] {
+		return errStore
+	}
+	return s.MemoryStore.Del(ctx, keys...)
+}
+
+func (s *failingStore) Expire(ctx context.Context, ttls map[string]time.Duration) error {
+	if s.fail["Expire"] {
+		return errStore
+	}
+	return s.MemoryStore.Expire(ctx, ttls)
+}
+
+func newLoggedCache(t *testing.T) (*scache.RedisCache[member, int], *failingStore, *recordLogger) {
+	db := scachetest.NewMemoryDB[member, int]("Id")
+	assert.Nil(t, db.Create(&member{Id: 1, Group: 1}))
+	assert.Nil(t, db.Create(&member{Id: 2, Group: 1}))
+	store := &failingStore{MemoryStore: scache.NewMemoryStore(), fail: make(map[string]bool)}
+	ca := scache.NewRedisCacheWithStore[member, int]("test", "member", "Id", db, store, time.Minute)
+	logger := &recordLogger{}
+	ca.SetLogger(logger)
+	return ca, store, logger
+}
+
+func TestLogSwallowedErrors(t *testing.T) {
+	cases := []struct {
+		fail string
+		msg  string
+		run  func(ca *scache.RedisCache[member, int]) error
+	}{
+		{"Del", "scache: clear cache after write failed", func(ca *scache.RedisCache[member, int]) error {
+			_, err := ca.Update(1, map[string]interface{}{"Group": 2})
+			return err
+		}},
+		{"Expire", "scache: refresh ttl failed", func(ca *scache.RedisCache[member, int]) error {
+			// the second read is a hit, which refreshes the ttl
+			_, err := ca.Get(1)
+			return err
+		}},
+		{"MSet", "scache: fill cache failed", func(ca *scache.RedisCache[member, int]) error {
+			_, err := ca.List(1, 2)
+			return err
+		}},
+	}
+	for _, c := range cases {
+		ca, store, logger := newLoggedCache(t)
+		_, err := ca.Get(1)
+		assert.Nil(t, err)
+		store.fail[c.fail] = true
+		// the call succeeds, the error is logged
+		assert.Nil(t, c.run(ca), c.fail)
+		attrs := logger.attrs(c.msg)
+		if assert.NotNil(t, attrs, c.fail) {
+			assert.Equal(t, "member", attrs["table"], c.fail)
+			assert.ErrorIs(t, attrs["error"].(error), errStore, c.fail)
+		}
+	}
+}
+
+func TestLogDecodeErrors(t *testing.T) {
+	store := scache.NewMemoryStore()
+	r := scache.NewRedisHashJsonWithStore[member, int](store, time.Minute)
+	logger := &recordLogger{}
+	r.SetLogger(logger)
+	assert.Nil(t, r.HSetJson("members", member{Id: 1, Group: 1}))
+	assert.Nil(t, store.HSet(context.Background(), "members", map[string]string{"2": "{"}))
+
+	rs, err := r.HGetAllJson("members")
+	assert.Nil(t, err)
+	assert.Equal(t, []member{{Id: 1, Group: 1}}, rs)
+	rs, err = r.HMGetJson("members", 1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []member{{Id: 1, Group: 1}}, rs)
+	assert.Equal(t, 2, logger.Len())
+	attrs := logger.attrs("scache: decode cached entry failed")
+	assert.Equal(t, "members", attrs["key"])
+	assert.Equal(t, "2", attrs["field"])
+	assert.NotNil(t, attrs["error"])
+}
+
+func TestSlogLogger(t *testing.T) {
+	assert.Equal(t, slog.Default(), scache.NewSlogLogger(nil))
+
+	var buf bytes.Buffer
+	ca, store, _ := newLoggedCache(t)
+	ca.SetLogger(scache.NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	store.fail["Del"] = true
+	_, err := ca.Update(1, map[string]interface{}{"Group": 2})
+	assert.Nil(t, err)
+	out := buf.String()
+	assert.Contains(t, out, `"level":"WARN"`)
+	assert.Contains(t, out, `"msg":"scache: clear cache after write failed"`)
+	assert.Contains(t, out, `"table":"member"`)
+	assert.Contains(t, out, `"error":"cache unavailable: store failed"`)
+	assert.Contains(t, out, `"keys":[`)
+}
@@ -0,0 +1,312 @@
+package scache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// memoryEntry a string, a hash or a set, zero expireAt means no ttl
+type memoryEntry struct {
+	str      *string
+	hash     map[string]string
+	set      map[string]struct{}
+	expireAt time.Time
+}
+
+func (s *memoryEntry) expired(now time.Time) bool {
+	return !s.expireAt.IsZero() && !now.Before(s.expireAt)
+}
+
+// MemoryStore store in a map of the process, for tests and single process tools without redis.
+// Expired entries are removed on access, and swept once the writes since the last sweep outnumber the entries
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	writes  int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*memoryEntry)}
+}
+
+// Len number of entries, including expired ones not swept yet
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Flush remove all entries
+func (s *MemoryStore) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = make(map[string]*memoryEntry)
+}
+
+// get live entry of key, nil if missing
+func (s *MemoryStore) get(key string) *memoryEntry {
+	e, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	if e.expired(time.Now()) {
+		delete(s.entries, key)
+		return nil
+	}
+	return e
+}
+
+// put entry at key, and sweep expired entries when due
+func (s *MemoryStore) put(key string, e *memoryEntry) {
+	s.entries[key] = e
+	s.writes++
+	if s.writes < len(s.entries) {
+		return
+	}
+	s.writes = 0
+	now := time.Now()
+	for k, v := range s.entries {
+		if v.expired(now) {
+			delete(s.entries, k)
+		}
+	}
+}
+
+func expireAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func (s *MemoryStore) getString(key string) (string, error) {
+	e := s.get(key)
+	if e == nil {
+		return "", redis.Nil
+	}
+	if e.str == nil {
+		return "", ErrWrongType
+	}
+	return *e.str, nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.getString(key)
+}
+
+func (s *MemoryStore) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := make([]interface{}, len(keys))
+	for i, k := range keys {
+		// like redis, other types are missing
+		if v, err := s.getString(k); err == nil {
+			r[i] = v
+		}
+	}
+	return r, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(key, &memoryEntry{str: &value, expireAt: expireAt(ttl)})
+	return nil
+}
+
+func (s *MemoryStore) MSet(ctx context.Context, entries ...Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range entries {
+		value := v.Value
+		s.put(v.Key, &memoryEntry{str: &value, expireAt: expireAt(v.TTL)})
+	}
+	return nil
+}
+
+func (s *MemoryStore) Del(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range keys {
+		delete(s.entries, k)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Exists(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.get(key) != nil, nil
+}
+
+func (s *MemoryStore) Expire(ctx context.Context, ttls map[string]time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range ttls {
+		if e := s.get(k); e != nil {
+			e.expireAt = expireAt(v)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Incr(ctx context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	e := s.get(key)
+	if e != nil {
+		if e.str == nil {
+			return 0, ErrWrongType
+		}
+		var err error
+		if n, err = strconv.ParseInt(*e.str, 10, 64); err != nil {
+			return 0, err
+		}
+	}
+	n++
+	v := strconv.FormatInt(n, 10)
+	if e != nil {
+		e.str = &v
+		return n, nil
+	}
+	s.put(key, &memoryEntry{str: &v})
+	return n, nil
+}
+
+// getHash hash at key, nil if missing
+func (s *MemoryStore) getHash(key string) (map[string]string, error) {
+	e := s.get(key)
+	if e == nil {
+		return nil, nil
+	}
+	if e.hash == nil {
+		return nil, ErrWrongType
+	}
+	return e.hash, nil
+}
+
+func (s *MemoryStore) HGet(ctx context.Context, key, field string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, err := s.getHash(key)
+	if err != nil {
+		return "", err
+	}
+	v, ok := h[field]
+	if !ok {
+		return "", redis.Nil
+	}
+	return v, nil
+}
+
+func (s *MemoryStore) HMGet(ctx context.Context, key string, fields ...string) ([]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, err := s.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+	r := make([]interface{}, len(fields))
+	for i, f := range fields {
+		if v, ok := h[f]; ok {
+			r[i] = v
+		}
+	}
+	return r, nil
+}
+
+func (s *MemoryStore) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, err := s.getHash(key)
+	if err != nil {
+		return nil, err
+	}
+	r := make(map[string]string, len(h))
+	for k, v := range h {
+		r[k] = v
+	}
+	return r, nil
+}
+
+func (s *MemoryStore) HSet(ctx context.Context, key string, values map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, err := s.getHash(key)
+	if err != nil {
+		return err
+	}
+	if h == nil {
+		h = make(map[string]string, len(values))
+		s.put(key, &memoryEntry{hash: h})
+	}
+	for k, v := range values {
+		h[k] = v
+	}
+	return nil
+}
+
+func (s *MemoryStore) HDel(ctx context.Context, key string, fields ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, err := s.getHash(key)
+	if err != nil || h == nil {
+		return err
+	}
+	for _, f := range fields {
+		delete(h, f)
+	}
+	// like redis, empty hashes are removed
+	if len(h) == 0 {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+func (s *MemoryStore) SAdd(ctx context.Context, key string, ttl time.Duration, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.get(key)
+	if e == nil {
+		e = &memoryEntry{set: make(map[string]struct{}, len(members))}
+		s.put(key, e)
+	} else if e.set == nil {
+		return ErrWrongType
+	}
+	for _, v := range members {
+		e.set[v] = struct{}{}
+	}
+	e.expireAt = expireAt(ttl)
+	return nil
+}
+
+func (s *MemoryStore) SMembers(ctx context.Context, key string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.get(key)
+	if e == nil {
+		return nil, nil
+	}
+	if e.set == nil {
+		return nil, ErrWrongType
+	}
+	r := make([]string, 0, len(e.set))
+	for k := range e.set {
+		r = append(r, k)
+	}
+	return r, nil
+}
+
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
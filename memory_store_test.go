@@ -0,0 +1,57 @@
+package scache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/scache"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := scache.NewMemoryStore()
+	assert.Nil(t, s.MSet(ctx, scache.Entry{Key: "a", Value: "1", TTL: time.Minute}, scache.Entry{Key: "b", Value: "2", TTL: 20 * time.Millisecond}))
+	vs, err := s.MGet(ctx, "a", "b", "c")
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"1", "2", nil}, vs)
+	_, err = s.Get(ctx, "c")
+	assert.Equal(t, redis.Nil, err)
+
+	n, err := s.Incr(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+	// a missing key isn't created by Expire
+	assert.Nil(t, s.Expire(ctx, map[string]time.Duration{"a": 20 * time.Millisecond, "c": time.Minute}))
+	ok, _ := s.Exists(ctx, "c")
+	assert.False(t, ok)
+
+	assert.Nil(t, s.HSet(ctx, "h", map[string]string{"1": "x", "2": "y"}))
+	v, err := s.HGet(ctx, "h", "1")
+	assert.Nil(t, err)
+	assert.Equal(t, "x", v)
+	_, err = s.HGet(ctx, "h", "3")
+	assert.Equal(t, redis.Nil, err)
+	_, err = s.Get(ctx, "h")
+	assert.ErrorIs(t, err, scache.ErrWrongType)
+	vs, err = s.HMGet(ctx, "h", "2", "3")
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"y", nil}, vs)
+	assert.Nil(t, s.HDel(ctx, "h", "1", "2"))
+	ok, _ = s.Exists(ctx, "h")
+	assert.False(t, ok, "empty hash is removed")
+
+	assert.Nil(t, s.SAdd(ctx, "s", 20*time.Millisecond, "x", "y", "x"))
+	members, err := s.SMembers(ctx, "s")
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"x", "y"}, members)
+
+	time.Sleep(30 * time.Millisecond)
+	for _, k := range []string{"a", "b", "s"} {
+		ok, _ = s.Exists(ctx, k)
+		assert.False(t, ok, "%s expired", k)
+	}
+	assert.Equal(t, 0, s.Len())
+}
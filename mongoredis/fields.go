@@ -0,0 +1,79 @@
+package mongoredis
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/daqiancode/scache"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var bsonNamesCache sync.Map
+
+// bsonNames bson keys of the fields of t by go field name: the name of the bson tag, or the lowercased field name like the driver.
+// Fields of inline structs are included
+func bsonNames(t reflect.Type) map[string]string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if r, ok := bsonNamesCache.Load(t); ok {
+		return r.(map[string]string)
+	}
+	r := make(map[string]string)
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		if t.Kind() != reflect.Struct {
+			return
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, opts, _ := strings.Cut(f.Tag.Get("bson"), ",")
+			if name == "-" {
+				continue
+			}
+			if strings.Contains(","+opts+",", ",inline,") {
+				walk(f.Type)
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+			if _, ok := r[f.Name]; !ok {
+				r[f.Name] = name
+			}
+		}
+	}
+	walk(t)
+	v, _ := bsonNamesCache.LoadOrStore(t, r)
+	return v.(map[string]string)
+}
+
+// bsonField bson key of field of T, field is returned as is if it is not a go field name of T, eg. a bson key or a dotted path
+func bsonField[T any](field string) string {
+	if name, ok := bsonNames(reflect.TypeOf((*T)(nil)).Elem())[field]; ok {
+		return name
+	}
+	return field
+}
+
+// bsonFilter filter of index, whose fields may be go field names of T, eg. from scache tags
+func bsonFilter[T any](index scache.Index) bson.M {
+	r := make(bson.M, len(index))
+	for k, v := range index {
+		r[bsonField[T](k)] = v
+	}
+	return r
+}
+
+// bsonSort sort document of orderBys
+func bsonSort[T any](orderBys scache.OrderBys) bson.D {
+	r := make(bson.D, len(orderBys))
+	for i, v := range orderBys {
+		r[i] = bson.E{Key: bsonField[T](v.Field), Value: sortDirection(v.Asc)}
+	}
+	return r
+}
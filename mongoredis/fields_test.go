@@ -0,0 +1,29 @@
+package mongoredis
+
+import (
+	"testing"
+
+	"github.com/daqiancode/scache"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type base struct {
+	CreatedAt int64 `bson:"created_at"`
+}
+
+type order struct {
+	base   `bson:",inline"`
+	ID     string `bson:"_id"`
+	Uid    uint   `scache:"index"`
+	Status string `bson:"st,omitempty" scache:"index"`
+	Secret string `bson:"-"`
+}
+
+func TestBsonFilter(t *testing.T) {
+	index := scache.Index{"Uid": 1, "Status": "paid", "ID": "a", "addr.country": "uae"}
+	assert.Equal(t, bson.M{"uid": 1, "st": "paid", "_id": "a", "addr.country": "uae"}, bsonFilter[order](index))
+	assert.Equal(t, bson.M{"uid": uint(1)}, bsonFilter[order](scache.TagIndexes(order{Uid: 1})[0]))
+	assert.Equal(t, bson.D{{Key: "created_at", Value: -1}, {Key: "uid", Value: 1}}, bsonSort[order](scache.NewOrderBys("CreatedAt", false).Add("uid", true)))
+	assert.Equal(t, "Secret", bsonField[order]("Secret"))
+}
@@ -2,7 +2,7 @@ package mongoredis
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"reflect"
 	"time"
 
@@ -14,43 +14,47 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func NewMongoRedis[T scache.Table[I], I scache.IDType](prefix, database, collection, idField string, db *mongo.Client, red *redis.Client, ttl time.Duration) *scache.RedisCache[T, I] {
+// NewMongoRedis empty idField means the id field declared by scache tags of T
+func NewMongoRedis[T scache.Table[I], I scache.IDType](prefix, database, collection, idField string, db *mongo.Client, red *redis.Client, ttl time.Duration, opts ...scache.Option) *scache.RedisCache[T, I] {
+	if idField == "" {
+		idField = scache.MetaOf[T]().IdField
+	}
 	m := &Mongo[T, I]{
 		db:         db,
 		idField:    idField,
-		ctx:        context.Background(),
 		database:   database,
 		collection: collection,
 		c:          db.Database(database).Collection(collection),
 	}
-	rc := scache.NewRedisCache[T, I](prefix, collection, idField, m, red, ttl)
+	rc := scache.NewRedisCache[T, I](prefix, collection, idField, m, red, ttl, opts...)
 	return rc
 }
 
-func NewMongoRedisFull[T scache.Table[I], I scache.IDType](prefix, database, collection, idField string, db *mongo.Client, red *redis.Client, ttl time.Duration) *scache.FullRedisCache[T, I] {
+func NewMongoRedisFull[T scache.Table[I], I scache.IDType](prefix, database, collection, idField string, db *mongo.Client, red *redis.Client, ttl time.Duration, opts ...scache.Option) *scache.FullRedisCache[T, I] {
+	if idField == "" {
+		idField = scache.MetaOf[T]().IdField
+	}
 	m := &Mongo[T, I]{
 		db:         db,
 		idField:    idField,
-		ctx:        context.Background(),
 		database:   database,
 		collection: collection,
 		c:          db.Database(database).Collection(collection),
 	}
-	rc := scache.NewFullRedisCache[T, I](prefix, collection, idField, m, red, ttl)
+	rc := scache.NewFullRedisCache[T, I](prefix, collection, idField, m, red, ttl, opts...)
 	return rc
 }
 
 type Mongo[T scache.Table[I], I scache.IDType] struct {
 	db         *mongo.Client
 	idField    string
-	ctx        context.Context
 	database   string
 	collection string
 	c          *mongo.Collection
 }
 
 func (s *Mongo[T, I]) Close() error {
-	return s.db.Disconnect(s.ctx)
+	return s.db.Disconnect(context.Background())
 }
 
 func (s *Mongo[T, I]) DB() *mongo.Client {
@@ -58,34 +62,46 @@ func (s *Mongo[T, I]) DB() *mongo.Client {
 }
 
 func (s *Mongo[T, I]) Create(t *T) error {
+	return s.CreateCtx(context.Background(), t)
+}
+
+func (s *Mongo[T, I]) CreateCtx(ctx context.Context, t *T) error {
 	if scache.IsNullID((*t).GetID()) {
 		reflect.ValueOf(t).Elem().FieldByName(s.idField).SetString(primitive.NewObjectID().Hex())
 	}
-	_, err := s.c.InsertOne(s.ctx, *t)
-	return err
+	_, err := s.c.InsertOne(ctx, *t)
+	return scache.NewDBError("Create", s.collection, err)
 }
 
 func (s *Mongo[T, I]) Save(t *T) error {
+	return s.SaveCtx(context.Background(), t)
+}
+
+func (s *Mongo[T, I]) SaveCtx(ctx context.Context, t *T) error {
 	if t == nil {
 		return nil
 	}
 	id := (*t).GetID()
 	if scache.IsNullID(id) {
-		return s.Create(t)
+		return s.CreateCtx(ctx, t)
 	}
-	_, err := s.Get(id)
+	_, err := s.GetCtx(ctx, id)
 	if err != nil && err != scache.ErrRecordNotFound {
-		return nil
+		return err
 	}
 	if err == scache.ErrRecordNotFound {
-		return s.Create(t)
+		return s.CreateCtx(ctx, t)
 	}
 	query := bson.M{"_id": id}
-	err = s.c.FindOneAndReplace(s.ctx, query, *t).Err()
-	return err
+	err = s.c.FindOneAndReplace(ctx, query, *t).Err()
+	return scache.NewDBError("Save", s.collection, err)
 }
 
 func (s *Mongo[T, I]) Update(id I, values interface{}) (int64, error) {
+	return s.UpdateCtx(context.Background(), id, values)
+}
+
+func (s *Mongo[T, I]) UpdateCtx(ctx context.Context, id I, values interface{}) (int64, error) {
 	if scache.IsNullID(id) {
 		return 0, nil
 	}
@@ -95,17 +111,21 @@ func (s *Mongo[T, I]) Update(id I, values interface{}) (int64, error) {
 			setD = append(setD, bson.E{Key: k, Value: v})
 		}
 	} else {
-		return 0, errors.New("RedisMongo.Update not support this type of update values, only support map[string]interface{}")
+		return 0, fmt.Errorf("%w: Mongo.Update only supports map[string]interface{} values", scache.ErrNotSupported)
 	}
 	setValues := bson.D{{Key: "$set", Value: setD}}
-	rs, err := s.c.UpdateOne(s.ctx, bson.M{"_id": id}, setValues)
+	rs, err := s.c.UpdateOne(ctx, bson.M{"_id": id}, setValues)
 
 	if err != nil {
-		return 0, err
+		return 0, scache.NewDBError("Update", s.collection, err)
 	}
 	return rs.MatchedCount, nil
 }
 func (s *Mongo[T, I]) Delete(ids ...I) (int64, error) {
+	return s.DeleteCtx(context.Background(), ids...)
+}
+
+func (s *Mongo[T, I]) DeleteCtx(ctx context.Context, ids ...I) (int64, error) {
 	if len(ids) == 0 {
 		return 0, nil
 	}
@@ -122,50 +142,66 @@ func (s *Mongo[T, I]) Delete(ids ...I) (int64, error) {
 	// 	}
 	// }
 	query := bson.M{"_id": bson.M{"$in": ids}}
-	rs, err := s.c.DeleteMany(s.ctx, query)
+	rs, err := s.c.DeleteMany(ctx, query)
 	if err != nil {
-		return 0, err
+		return 0, scache.NewDBError("Delete", s.collection, err)
 	}
 
 	return rs.DeletedCount, err
 }
 func (s *Mongo[T, I]) Get(id I) (T, error) {
+	return s.GetCtx(context.Background(), id)
+}
+
+func (s *Mongo[T, I]) GetCtx(ctx context.Context, id I) (T, error) {
 	var t T
-	r := s.c.FindOne(s.ctx, bson.M{"_id": id})
+	r := s.c.FindOne(ctx, bson.M{"_id": id})
 	if err := r.Err(); err != nil {
 		if mongo.ErrNoDocuments == err {
 			return t, scache.ErrRecordNotFound
 		}
-		return t, err
+		return t, scache.NewDBError("Get", s.collection, err)
 	}
 	err := r.Decode(&t)
-	return t, err
+	return t, scache.NewDBError("Get", s.collection, err)
 }
 func (s *Mongo[T, I]) GetBy(index scache.Index) (T, error) {
+	return s.GetByCtx(context.Background(), index)
+}
+
+func (s *Mongo[T, I]) GetByCtx(ctx context.Context, index scache.Index) (T, error) {
 	var t T
-	r := s.c.FindOne(s.ctx, index)
+	r := s.c.FindOne(ctx, bsonFilter[T](index))
 	if err := r.Err(); err != nil {
 		if mongo.ErrNoDocuments == err {
 			return t, scache.ErrRecordNotFound
 		}
-		return t, err
+		return t, scache.NewDBError("GetBy", s.collection, err)
 	}
 	err := r.Decode(&t)
-	return t, err
+	return t, scache.NewDBError("GetBy", s.collection, err)
 }
 func (s *Mongo[T, I]) List(ids ...I) ([]T, error) {
+	return s.ListCtx(context.Background(), ids...)
+}
+
+func (s *Mongo[T, I]) ListCtx(ctx context.Context, ids ...I) ([]T, error) {
 	var t []T
 	var err error
 	query := bson.M{"_id": bson.M{"$in": ids}}
-	r, err := s.c.Find(s.ctx, query)
+	r, err := s.c.Find(ctx, query)
 	if err != nil {
-		return t, err
+		return t, scache.NewDBError("List", s.collection, err)
 	}
 	// err = r.Decode(&t)
-	err = r.All(s.ctx, &t)
-	return t, err
+	err = r.All(ctx, &t)
+	return t, scache.NewDBError("List", s.collection, err)
 }
 func (s *Mongo[T, I]) ListBy(index scache.Index, orderBys scache.OrderBys) ([]T, error) {
+	return s.ListByCtx(context.Background(), index, orderBys)
+}
+
+func (s *Mongo[T, I]) ListByCtx(ctx context.Context, index scache.Index, orderBys scache.OrderBys) ([]T, error) {
 	var t []T
 	var err error
 	// objectIds := make([]primitive.ObjectID, len(ids))
@@ -178,53 +214,95 @@ func (s *Mongo[T, I]) ListBy(index scache.Index, orderBys scache.OrderBys) ([]T,
 	// }
 	var opts *options.FindOptions
 	if len(orderBys) > 0 {
-		ds := make([]bson.E, len(orderBys))
-		for i, v := range orderBys {
-			ds[i] = bson.E{Key: v.Field, Value: v.Asc}
-		}
-		opts = options.Find().SetSort(ds)
+		opts = options.Find().SetSort(bsonSort[T](orderBys))
 	}
 
-	r, err := s.c.Find(s.ctx, index, opts)
+	r, err := s.c.Find(ctx, bsonFilter[T](index), opts)
 	if err != nil {
-		return t, err
+		return t, scache.NewDBError("ListBy", s.collection, err)
 	}
 	// err = r.Decode(&t)
-	err = r.All(s.ctx, &t)
-	return t, err
+	err = r.All(ctx, &t)
+	return t, scache.NewDBError("ListBy", s.collection, err)
+}
+func (s *Mongo[T, I]) CountBy(index scache.Index) (int64, error) {
+	return s.CountByCtx(context.Background(), index)
 }
+
+func (s *Mongo[T, I]) CountByCtx(ctx context.Context, index scache.Index) (int64, error) {
+	r, err := s.c.CountDocuments(ctx, bsonFilter[T](index))
+	return r, scache.NewDBError("CountBy", s.collection, err)
+}
+
+func (s *Mongo[T, I]) ListByPage(index scache.Index, orderBys scache.OrderBys, offset, limit int) ([]T, error) {
+	return s.ListByPageCtx(context.Background(), index, orderBys, offset, limit)
+}
+
+func (s *Mongo[T, I]) ListByPageCtx(ctx context.Context, index scache.Index, orderBys scache.OrderBys, offset, limit int) ([]T, error) {
+	var t []T
+	opts := options.Find().SetSkip(int64(offset)).SetLimit(int64(limit))
+	if len(orderBys) > 0 {
+		opts.SetSort(bsonSort[T](orderBys))
+	}
+	r, err := s.c.Find(ctx, bsonFilter[T](index), opts)
+	if err != nil {
+		return t, scache.NewDBError("ListByPage", s.collection, err)
+	}
+	err = r.All(ctx, &t)
+	return t, scache.NewDBError("ListByPage", s.collection, err)
+}
+
+func sortDirection(asc bool) int {
+	if asc {
+		return 1
+	}
+	return -1
+}
+
 func (s *Mongo[T, I]) ListAll() ([]T, error) {
+	return s.ListAllCtx(context.Background())
+}
+
+func (s *Mongo[T, I]) ListAllCtx(ctx context.Context) ([]T, error) {
 	var t []T
-	r, err := s.c.Find(s.ctx, bson.D{})
+	r, err := s.c.Find(ctx, bson.D{})
 	if err != nil {
-		return t, err
+		return t, scache.NewDBError("ListAll", s.collection, err)
 	}
-	err = r.All(s.ctx, &t)
-	return t, err
+	err = r.All(ctx, &t)
+	return t, scache.NewDBError("ListAll", s.collection, err)
 }
 
 func (s *Mongo[T, I]) ListByUniqueInts(field string, values []int64) ([]T, error) {
+	return s.ListByUniqueIntsCtx(context.Background(), field, values)
+}
+
+func (s *Mongo[T, I]) ListByUniqueIntsCtx(ctx context.Context, field string, values []int64) ([]T, error) {
 	var t []T
 	var err error
-	query := bson.M{field: bson.M{"$in": values}}
-	r, err := s.c.Find(s.ctx, query)
+	query := bson.M{bsonField[T](field): bson.M{"$in": values}}
+	r, err := s.c.Find(ctx, query)
 	if err != nil {
-		return t, err
+		return t, scache.NewDBError("ListByUniqueInts", s.collection, err)
 	}
 	// err = r.Decode(&t)
-	err = r.All(s.ctx, &t)
-	return t, err
+	err = r.All(ctx, &t)
+	return t, scache.NewDBError("ListByUniqueInts", s.collection, err)
 }
 
 func (s *Mongo[T, I]) ListByUniqueStrs(field string, values []string) ([]T, error) {
+	return s.ListByUniqueStrsCtx(context.Background(), field, values)
+}
+
+func (s *Mongo[T, I]) ListByUniqueStrsCtx(ctx context.Context, field string, values []string) ([]T, error) {
 	var t []T
 	var err error
-	query := bson.M{field: bson.M{"$in": values}}
-	r, err := s.c.Find(s.ctx, query)
+	query := bson.M{bsonField[T](field): bson.M{"$in": values}}
+	r, err := s.c.Find(ctx, query)
 	if err != nil {
-		return t, err
+		return t, scache.NewDBError("ListByUniqueStrs", s.collection, err)
 	}
 	// err = r.Decode(&t)
-	err = r.All(s.ctx, &t)
-	return t, err
+	err = r.All(ctx, &t)
+	return t, scache.NewDBError("ListByUniqueStrs", s.collection, err)
 }
@@ -2,7 +2,7 @@ package mongoredis
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"reflect"
 	"time"
 
@@ -57,7 +57,7 @@ func (s *RedisMongo[T, I]) ClearCache(id I, indexes scache.Indexes) error {
 	keys = scache.UniqueStrings(keys)
 	ctx, cancel := context.WithTimeout(context.Background(), MongoOpTimeout)
 	defer cancel()
-	return s.red.Del(ctx, keys...).Err()
+	return s.red.DelCtx(ctx, keys...)
 }
 
 func (s *RedisMongo[T, I]) Get(id I) (T, bool, error) {
@@ -69,10 +69,10 @@ func (s *RedisMongo[T, I]) Get(id I) (T, bool, error) {
 		if mongo.ErrNoDocuments == err {
 			return t, false, nil
 		}
-		return t, false, err
+		return t, false, scache.NewDBError("Get", s.collection, err)
 	}
 	err := r.Decode(&t)
-	return t, true, err
+	return t, true, scache.NewDBError("Get", s.collection, err)
 }
 
 func (s *RedisMongo[T, I]) List(ids ...I) ([]T, error) {
@@ -91,11 +91,11 @@ func (s *RedisMongo[T, I]) List(ids ...I) ([]T, error) {
 	query := bson.M{"_id": bson.M{"$in": ids}}
 	r, err := s.c.Find(ctx, query)
 	if err != nil {
-		return t, err
+		return t, scache.NewDBError("List", s.collection, err)
 	}
 	// err = r.Decode(&t)
 	err = r.All(ctx, &t)
-	return t, err
+	return t, scache.NewDBError("List", s.collection, err)
 }
 
 func (s *RedisMongo[T, I]) Create(t *T) error {
@@ -109,7 +109,7 @@ func (s *RedisMongo[T, I]) Create(t *T) error {
 	defer cancel()
 	_, err := s.c.InsertOne(ctx, *t)
 	if err != nil {
-		return err
+		return scache.NewDBError("Create", s.collection, err)
 	}
 	s.ClearCache((*t).GetID(), (*t).ListIndexes())
 	return nil
@@ -124,7 +124,7 @@ func (s *RedisMongo[T, I]) Save(t *T) error {
 	}
 	_, exist, err := s.Get(id)
 	if err != nil {
-		return nil
+		return err
 	}
 	if !exist {
 		return s.Create(t)
@@ -133,7 +133,7 @@ func (s *RedisMongo[T, I]) Save(t *T) error {
 	ctx, cancel := context.WithTimeout(context.Background(), MongoOpTimeout)
 	defer cancel()
 	err = s.c.FindOneAndReplace(ctx, query, *t).Err()
-	return err
+	return scache.NewDBError("Save", s.collection, err)
 }
 
 func (s *RedisMongo[T, I]) Delete(ids ...I) (int64, error) {
@@ -157,7 +157,7 @@ func (s *RedisMongo[T, I]) Delete(ids ...I) (int64, error) {
 	query := bson.M{"_id": bson.M{"$in": ids}}
 	rs, err := s.c.DeleteMany(ctx, query)
 	if err != nil {
-		return 0, err
+		return 0, scache.NewDBError("Delete", s.collection, err)
 	}
 	for _, v := range objs {
 		s.ClearCache(v.GetID(), v.ListIndexes())
@@ -184,7 +184,7 @@ func (s *RedisMongo[T, I]) Update(id I, values interface{}) (int64, error) {
 			setD = append(setD, bson.E{Key: k, Value: v})
 		}
 	} else {
-		return 0, errors.New("RedisMongo.Update not support this type of update values, only support map[string]interface{}")
+		return 0, fmt.Errorf("%w: RedisMongo.Update only supports map[string]interface{} values", scache.ErrNotSupported)
 	}
 	setValues := bson.D{{Key: "$set", Value: setD}}
 	ctx, cancel := context.WithTimeout(context.Background(), MongoOpTimeout)
@@ -192,7 +192,7 @@ func (s *RedisMongo[T, I]) Update(id I, values interface{}) (int64, error) {
 	rs, err := s.c.UpdateOne(ctx, bson.M{"_id": id}, setValues)
 
 	if err != nil {
-		return 0, err
+		return 0, scache.NewDBError("Update", s.collection, err)
 	}
 	newObj, _, err := s.Get(id)
 	if err != nil {
@@ -207,15 +207,15 @@ func (s *RedisMongo[T, I]) GetBy(index scache.Index) (T, bool, error) {
 	var t T
 	ctx, cancel := context.WithTimeout(context.Background(), MongoOpTimeout)
 	defer cancel()
-	r := s.c.FindOne(ctx, index)
+	r := s.c.FindOne(ctx, bsonFilter[T](index))
 	if err := r.Err(); err != nil {
 		if mongo.ErrNoDocuments == err {
 			return t, false, nil
 		}
-		return t, false, err
+		return t, false, scache.NewDBError("GetBy", s.collection, err)
 	}
 	err := r.Decode(&t)
-	return t, true, err
+	return t, true, scache.NewDBError("GetBy", s.collection, err)
 }
 
 func (s *RedisMongo[T, I]) ListBy(index scache.Index, initOrders scache.OrderBys) ([]T, error) {
@@ -231,19 +231,15 @@ func (s *RedisMongo[T, I]) ListBy(index scache.Index, initOrders scache.OrderBys
 	// }
 	var opts *options.FindOptions
 	if len(initOrders) > 0 {
-		ds := make([]bson.E, len(initOrders))
-		for i, v := range initOrders {
-			ds[i] = bson.E{Key: v.Field, Value: v.Asc}
-		}
-		opts = options.Find().SetSort(ds)
+		opts = options.Find().SetSort(bsonSort[T](initOrders))
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), MongoOpTimeout)
 	defer cancel()
-	r, err := s.c.Find(ctx, index, opts)
+	r, err := s.c.Find(ctx, bsonFilter[T](index), opts)
 	if err != nil {
-		return t, err
+		return t, scache.NewDBError("ListBy", s.collection, err)
 	}
 	// err = r.Decode(&t)
 	err = r.All(ctx, &t)
-	return t, err
+	return t, scache.NewDBError("ListBy", s.collection, err)
 }
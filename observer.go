@@ -0,0 +1,216 @@
+package scache
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type EventKind int
+
+const (
+	// EventHit records found in cache
+	EventHit EventKind = iota
+	// EventMiss records not in cache
+	EventMiss
+	// EventNullHit records cached as not found
+	EventNullHit
+	// EventDBLoad records loaded from db on misses
+	EventDBLoad
+	// EventSet cache entries filled
+	EventSet
+	// EventInvalidation cache entries cleared
+	EventInvalidation
+	// EventError a redis or db operation failed, Err is set
+	EventError
+)
+
+func (s EventKind) String() string {
+	switch s {
+	case EventHit:
+		return "hit"
+	case EventMiss:
+		return "miss"
+	case EventNullHit:
+		return "null_hit"
+	case EventDBLoad:
+		return "db_load"
+	case EventSet:
+		return "set"
+	case EventInvalidation:
+		return "invalidation"
+	case EventError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// Event something happened in a cache
+type Event struct {
+	Table string
+	// Op method of the cache, eg. Get, List, GetBy, ListBy, ListAll, ClearCache
+	Op   string
+	Kind EventKind
+	// Keys number of records or cache keys involved
+	Keys int
+	// Duration of the redis or db operation
+	Duration time.Duration
+	Err      error
+}
+
+// Observer receive events of caches, it is called synchronously, so it must be fast and safe for concurrent use
+type Observer interface {
+	Observe(e Event)
+}
+
+// SetObserver receive hit, miss, db load, set, invalidation and error events of the cache, nil to disable
+func (s *CacheBase[T, I]) SetObserver(observer Observer) {
+	s.observer = observer
+}
+func (s *CacheBase[T, I]) GetObserver() Observer {
+	return s.observer
+}
+
+// observe send an event of keys since start, an error event if err is not nil
+func (s *CacheBase[T, I]) observe(op string, kind EventKind, keys int, start time.Time, err error) {
+	if s.observer == nil {
+		return
+	}
+	if err != nil {
+		kind = EventError
+	}
+	s.observer.Observe(Event{Table: s.table, Op: op, Kind: kind, Keys: keys, Duration: time.Since(start), Err: err})
+}
+
+// observeHit send a hit or null hit event
+func (s *CacheBase[T, I]) observeHit(op string, h hit, start time.Time) {
+	if h.null {
+		s.observe(op, EventNullHit, 1, start, nil)
+		return
+	}
+	s.observe(op, EventHit, 1, start, nil)
+}
+
+// observeBatch send hit, null hit and miss events of a read of n keys
+func (s *CacheBase[T, I]) observeBatch(op string, n, misses, nulls int, start time.Time) {
+	if hits := n - misses - nulls; hits > 0 {
+		s.observe(op, EventHit, hits, start, nil)
+	}
+	if nulls > 0 {
+		s.observe(op, EventNullHit, nulls, start, nil)
+	}
+	if misses > 0 {
+		s.observe(op, EventMiss, misses, start, nil)
+	}
+}
+
+// Counter totals of events of a kind
+type Counter struct {
+	Events   int64
+	Keys     int64
+	Duration time.Duration
+}
+
+// AvgDuration average duration of events
+func (s Counter) AvgDuration() time.Duration {
+	if s.Events == 0 {
+		return 0
+	}
+	return s.Duration / time.Duration(s.Events)
+}
+
+// TableStats counters of a table
+type TableStats struct {
+	Hit          Counter
+	Miss         Counter
+	NullHit      Counter
+	DBLoad       Counter
+	Set          Counter
+	Invalidation Counter
+	Error        Counter
+}
+
+// HitRatio (hit+null hit)/(hit+null hit+miss) by keys
+func (s TableStats) HitRatio() float64 {
+	hits := s.Hit.Keys + s.NullHit.Keys
+	if hits+s.Miss.Keys == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+s.Miss.Keys)
+}
+
+func (s *TableStats) counter(kind EventKind) *Counter {
+	switch kind {
+	case EventHit:
+		return &s.Hit
+	case EventMiss:
+		return &s.Miss
+	case EventNullHit:
+		return &s.NullHit
+	case EventDBLoad:
+		return &s.DBLoad
+	case EventSet:
+		return &s.Set
+	case EventInvalidation:
+		return &s.Invalidation
+	case EventError:
+		return &s.Error
+	}
+	return nil
+}
+
+// Counters in-memory Observer counting events per table, eg. share one Counters by all caches and export Stats to metrics
+type Counters struct {
+	mu     sync.Mutex
+	tables map[string]*TableStats
+}
+
+func NewCounters() *Counters {
+	return &Counters{tables: make(map[string]*TableStats)}
+}
+
+func (s *Counters) Observe(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats, ok := s.tables[e.Table]
+	if !ok {
+		stats = &TableStats{}
+		s.tables[e.Table] = stats
+	}
+	c := stats.counter(e.Kind)
+	if c == nil {
+		return
+	}
+	c.Events++
+	c.Keys += int64(e.Keys)
+	c.Duration += e.Duration
+}
+
+// Stats counters of table, zero if no event of table yet
+func (s *Counters) Stats(table string) TableStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stats, ok := s.tables[table]; ok {
+		return *stats
+	}
+	return TableStats{}
+}
+
+// Tables tables which have events, sorted
+func (s *Counters) Tables() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := make([]string, 0, len(s.tables))
+	for k := range s.tables {
+		r = append(r, k)
+	}
+	sort.Strings(r)
+	return r
+}
+
+// Reset clear all counters
+func (s *Counters) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tables = make(map[string]*TableStats)
+}
@@ -0,0 +1,150 @@
+package scache_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/scache"
+	"github.com/daqiancode/scache/scachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounters(t *testing.T) {
+	c := scache.NewCounters()
+	c.Observe(scache.Event{Table: "user", Op: "Get", Kind: scache.EventHit, Keys: 3, Duration: time.Millisecond})
+	c.Observe(scache.Event{Table: "user", Op: "List", Kind: scache.EventMiss, Keys: 1, Duration: time.Millisecond})
+	c.Observe(scache.Event{Table: "user", Op: "Get", Kind: scache.EventDBLoad, Keys: 1, Duration: 4 * time.Millisecond})
+	c.Observe(scache.Event{Table: "user", Op: "Get", Kind: scache.EventDBLoad, Keys: 1, Duration: 2 * time.Millisecond})
+	c.Observe(scache.Event{Table: "order", Op: "Get", Kind: scache.EventError, Keys: 1, Err: errors.New("timeout")})
+
+	user := c.Stats("user")
+	assert.Equal(t, int64(3), user.Hit.Keys)
+	assert.Equal(t, int64(1), user.Miss.Events)
+	assert.Equal(t, 0.75, user.HitRatio())
+	assert.Equal(t, int64(2), user.DBLoad.Events)
+	assert.Equal(t, 3*time.Millisecond, user.DBLoad.AvgDuration())
+	assert.Equal(t, int64(1), c.Stats("order").Error.Events)
+	assert.Equal(t, scache.TableStats{}, c.Stats("none"))
+	assert.Equal(t, []string{"order", "user"}, c.Tables())
+
+	c.Reset()
+	assert.Empty(t, c.Tables())
+}
+
+// recordObserver keeps the events observed
+type recordObserver struct {
+	mu     sync.Mutex
+	events []scache.Event
+}
+
+func (s *recordObserver) Observe(e scache.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+}
+
+// take return the events since the last take
+func (s *recordObserver) take() []scache.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.events
+	s.events = nil
+	return r
+}
+
+// assertEvents assert events are of kinds with keys in order, on table, with a duration
+func assertEvents(t *testing.T, events []scache.Event, op string, kinds []scache.EventKind, keys []int) {
+	t.Helper()
+	if !assert.Len(t, events, len(kinds), op) {
+		return
+	}
+	for i, e := range events {
+		assert.Equal(t, "member", e.Table, op)
+		assert.Equal(t, op, e.Op)
+		assert.Equal(t, kinds[i], e.Kind, op)
+		assert.Equal(t, keys[i], e.Keys, op)
+		assert.Greater(t, e.Duration, time.Duration(0), op)
+		assert.Equal(t, e.Kind == scache.EventError, e.Err != nil, op)
+	}
+}
+
+func TestRedisCacheObserver(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	db := &slowDB{MemoryDB: scachetest.NewMemoryDB[member, int]("Id"), delay: time.Millisecond}
+	assert.Nil(t, db.Create(&member{Id: 1, Group: 1}))
+	ca := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	o := &recordObserver{}
+	ca.SetObserver(o)
+
+	_, err := ca.Get(1)
+	assert.Nil(t, err)
+	events := o.take()
+	assertEvents(t, events, "Get", []scache.EventKind{scache.EventMiss, scache.EventDBLoad, scache.EventSet}, []int{1, 1, 1})
+	assert.GreaterOrEqual(t, events[1].Duration, db.delay)
+	_, err = ca.Get(1)
+	assert.Nil(t, err)
+	assertEvents(t, o.take(), "Get", []scache.EventKind{scache.EventHit}, []int{1})
+	_, err = ca.Get(2)
+	assert.Equal(t, scache.ErrRecordNotFound, err)
+	o.take()
+	_, err = ca.Get(2)
+	assert.Equal(t, scache.ErrRecordNotFound, err)
+	assertEvents(t, o.take(), "Get", []scache.EventKind{scache.EventNullHit}, []int{1})
+	rs, err := ca.List(1, 2, 3)
+	assert.Nil(t, err)
+	assert.Len(t, rs, 3)
+	assertEvents(t, o.take(), "List", []scache.EventKind{scache.EventHit, scache.EventNullHit, scache.EventMiss, scache.EventDBLoad, scache.EventSet}, []int{1, 1, 1, 1, 1})
+
+	// primary key and the Group index
+	assert.Nil(t, ca.ClearCache(member{Id: 1, Group: 1}))
+	assertEvents(t, o.take(), "ClearCache", []scache.EventKind{scache.EventInvalidation}, []int{2})
+
+	mr.SetError("boom")
+	_, err = ca.Get(1)
+	assert.ErrorIs(t, err, scache.ErrCacheUnavailable)
+	assertEvents(t, o.take(), "Get", []scache.EventKind{scache.EventError}, []int{1})
+}
+
+func TestFullRedisCacheObserver(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	db := scachetest.NewMemoryDB[member, int]("Id")
+	assert.Nil(t, db.Create(&member{Id: 1, Group: 1}))
+	assert.Nil(t, db.Create(&member{Id: 2, Group: 1}))
+	ca := scache.NewFullRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	o := &recordObserver{}
+	ca.SetObserver(o)
+
+	_, err := ca.Get(1)
+	assert.Nil(t, err)
+	events := o.take()
+	assertEvents(t, events[:1], "Get", []scache.EventKind{scache.EventMiss}, []int{1})
+	assertEvents(t, events[1:], "Load", []scache.EventKind{scache.EventMiss, scache.EventDBLoad, scache.EventSet}, []int{1, 2, 2})
+	_, err = ca.Get(1)
+	assert.Nil(t, err)
+	assertEvents(t, o.take(), "Get", []scache.EventKind{scache.EventHit}, []int{1})
+
+	_, err = ca.GetBy(scache.NewIndex("Group", 2))
+	assert.Equal(t, scache.ErrRecordNotFound, err)
+	assertEvents(t, o.take(), "GetBy", []scache.EventKind{scache.EventMiss, scache.EventDBLoad, scache.EventSet}, []int{1, 1, 1})
+	_, err = ca.GetBy(scache.NewIndex("Group", 2))
+	assert.Equal(t, scache.ErrRecordNotFound, err)
+	assertEvents(t, o.take(), "GetBy", []scache.EventKind{scache.EventNullHit}, []int{1})
+
+	assert.Nil(t, ca.ClearCache(member{Id: 1, Group: 1}))
+	events = o.take()
+	if assert.NotEmpty(t, events) {
+		for _, e := range events {
+			assert.Equal(t, "ClearCache", e.Op)
+			assert.Equal(t, scache.EventInvalidation, e.Kind)
+			assert.Equal(t, "member", e.Table)
+			assert.Greater(t, e.Keys, 0)
+		}
+	}
+
+	mr.SetError("boom")
+	_, err = ca.Get(1)
+	assert.ErrorIs(t, err, scache.ErrCacheUnavailable)
+	assertEvents(t, o.take(), "Get", []scache.EventKind{scache.EventError}, []int{1})
+}
@@ -0,0 +1,23 @@
+package scache
+
+// Option configure a cache at construction, eg. NewRedisCache(prefix, table, "", db, red, ttl, WithSerializer(&MsgpackSerializer{}))
+type Option func(o *options)
+
+type options struct {
+	serializer Serializer
+}
+
+// WithSerializer encode cached objects with serializer instead of JsonSerializer
+func WithSerializer(serializer Serializer) Option {
+	return func(o *options) {
+		o.serializer = serializer
+	}
+}
+
+func newOptions(opts []Option) options {
+	var r options
+	for _, v := range opts {
+		v(&r)
+	}
+	return r
+}
@@ -0,0 +1,212 @@
+package scache
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// MaxPageList ids cached in a page list, pages reaching past it are read from db. <=0 means all records by index are cached
+var MaxPageList = 10000
+
+// pageHeader first item of cached id lists, so that the lists of empty results exist
+const pageHeader = ""
+
+// variantsKey redis set of keys derived from key, eg. the id lists of an index in every order, deleted with key
+func variantsKey(key string) string {
+	return key + "/variants"
+}
+
+// orderKey part of cache keys for orderBys, eg. name.asc,id.desc
+func orderKey(orderBys OrderBys) string {
+	parts := make([]string, len(orderBys))
+	for i, v := range orderBys {
+		dir := "desc"
+		if v.Asc {
+			dir = "asc"
+		}
+		parts[i] = strings.ToLower(v.Field) + "." + dir
+	}
+	return strings.Join(parts, ",")
+}
+
+// PageKey redis list of the ids of records by index in order of orderBys, used by ListByPage and ListByCursor
+func (s *RedisCache[T, I]) PageKey(index Index, orderBys OrderBys) string {
+	r := s.MakeCacheKey(index) + "/page"
+	if len(orderBys) > 0 {
+		r += "/" + orderKey(orderBys)
+	}
+	return r
+}
+
+// KEYS[1] list key, KEYS[2] variants key of the index, KEYS[3] lease key.
+// ARGV[1] lease token, empty means fill unconditionally, ARGV[2] ttl in milliseconds, ARGV[3:] items
+var fillPageScript = redis.NewScript(`
+if ARGV[1] ~= '' and redis.call('GET', KEYS[3]) ~= ARGV[1] then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+for i = 3, #ARGV, 1000 do
+	redis.call('RPUSH', KEYS[1], unpack(ARGV, i, math.min(i + 999, #ARGV)))
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+redis.call('SADD', KEYS[2], KEYS[1])
+-- the variants are shared, only extend their ttl
+local ttl = redis.call('PTTL', KEYS[2])
+if ttl >= 0 and ttl < tonumber(ARGV[2]) then
+	redis.call('PEXPIRE', KEYS[2], ARGV[2])
+end
+if ARGV[1] ~= '' then
+	redis.call('DEL', KEYS[3])
+end
+return 1
+`)
+
+// ListByPage list records by index from offset, at most limit records.
+// Ids of records by index are cached in a redis list, only one page of them is read and hydrated by List.
+// On a miss, the page is read from db, and the list of the first MaxPageList ids is built in background.
+// Pages reaching past MaxPageList and pages of stores not backed by redis are read from db
+func (s *RedisCache[T, I]) ListByPage(index Index, orderBys OrderBys, offset, limit int) ([]T, error) {
+	return s.ListByPageCtx(context.Background(), index, orderBys, offset, limit)
+}
+
+func (s *RedisCache[T, I]) ListByPageCtx(ctx context.Context, index Index, orderBys OrderBys, offset, limit int) ([]T, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, nil
+	}
+	s.syncGeneration(ctx)
+	// page lists need a redis store
+	if s.client() == nil || (MaxPageList > 0 && offset+limit > MaxPageList) || !s.cacheAvailable(ctx) {
+		return s.db.ListByPageCtx(ctx, index, orderBys, offset, limit)
+	}
+	key := s.PageKey(index, orderBys)
+	start := time.Now()
+	ids, found, err := s.pageIds(ctx, key, offset, limit)
+	if err != nil {
+		s.observe("ListByPage", EventError, 1, start, err)
+		if s.cacheFailed(ctx, err) {
+			return s.db.ListByPageCtx(ctx, index, orderBys, offset, limit)
+		}
+		return nil, err
+	}
+	s.cacheSucceeded()
+	if found {
+		s.observe("ListByPage", EventHit, 1, start, nil)
+		return s.ListCtx(ctx, ids...)
+	}
+	s.observe("ListByPage", EventMiss, 1, start, nil)
+	s.buildPage(index, orderBys, key)
+	start = time.Now()
+	r, err := s.db.ListByPageCtx(ctx, index, orderBys, offset, limit)
+	s.observe("ListByPage", EventDBLoad, len(r), start, err)
+	return r, err
+}
+
+// ListByCursor list at most limit records by index after cursor, empty cursor means the first page.
+// It returns the cursor of the next page, empty if there are no more records.
+// The cursor is an offset in the cached list, pages may shift when records are written meanwhile
+func (s *RedisCache[T, I]) ListByCursor(index Index, orderBys OrderBys, cursor string, limit int) ([]T, string, error) {
+	return s.ListByCursorCtx(context.Background(), index, orderBys, cursor, limit)
+}
+
+func (s *RedisCache[T, I]) ListByCursorCtx(ctx context.Context, index Index, orderBys OrderBys, cursor string, limit int) ([]T, string, error) {
+	offset := 0
+	if cursor != "" {
+		var err error
+		if offset, err = strconv.Atoi(cursor); err != nil || offset < 0 {
+			return nil, "", ErrInvalidCursor
+		}
+	}
+	// one more record tells whether there is a next page
+	r, err := s.ListByPageCtx(ctx, index, orderBys, offset, limit+1)
+	if err != nil || len(r) <= limit {
+		return r, "", err
+	}
+	return r[:limit], strconv.Itoa(offset + limit), nil
+}
+
+// pageIds read ids in [offset,offset+limit) from the list at key, found=false if the list is not cached
+func (s *RedisCache[T, I]) pageIds(ctx context.Context, key string, offset, limit int) ([]I, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	p := s.client().Pipeline()
+	exists := p.Exists(ctx, key)
+	// skip the header
+	items := p.LRange(ctx, key, int64(offset+1), int64(offset+limit))
+	if _, err := p.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, false, cacheError(err)
+	}
+	if exists.Val() == 0 {
+		return nil, false, nil
+	}
+	ids := make([]I, len(items.Val()))
+	for i, v := range items.Val() {
+		if err := json.UnmarshalFromString(v, &ids[i]); err != nil {
+			return nil, false, decodeError(err)
+		}
+	}
+	return ids, true, nil
+}
+
+// buildPage cache the ids of the first MaxPageList records by index in background, at most one build per key at a time.
+// It is not bound to a request, a build started after an invalidation completes even if its reader is gone
+func (s *RedisCache[T, I]) buildPage(index Index, orderBys OrderBys, key string) {
+	indexKey := s.MakeCacheKey(index)
+	s.sf.DoChan("page:"+key, func() (interface{}, error) {
+		// the request context may be done before the build
+		ctx := context.Background()
+		err := s.fillPage(ctx, index, orderBys, indexKey, key)
+		if err != nil {
+			s.warn("scache: build page list failed", err, "key", key)
+		}
+		return nil, err
+	})
+}
+
+func (s *RedisCache[T, I]) fillPage(ctx context.Context, index Index, orderBys OrderBys, indexKey, key string) error {
+	// register the list before loading, so that ClearCache revokes its lease
+	if err := s.registerVariant(ctx, index, key); err != nil {
+		return err
+	}
+	l, _, err := s.acquireLease(ctx, key)
+	if err != nil {
+		return err
+	}
+	if l != nil && l.token == "" {
+		// built by another reader
+		return nil
+	}
+	start := time.Now()
+	var rs []T
+	if MaxPageList > 0 {
+		rs, err = s.db.ListByPageCtx(ctx, index, orderBys, 0, MaxPageList)
+	} else {
+		rs, err = s.db.ListByCtx(ctx, index, orderBys)
+	}
+	s.observe("ListByPage", EventDBLoad, len(rs), start, err)
+	if err != nil {
+		return err
+	}
+	args := make([]interface{}, 0, len(rs)+3)
+	token := ""
+	if l != nil {
+		token = l.token
+	}
+	args = append(args, token, s.redIds.expiration().Milliseconds(), pageHeader)
+	for _, v := range rs {
+		id, err := json.MarshalToString(v.GetID())
+		if err != nil {
+			return err
+		}
+		args = append(args, id)
+	}
+	start = time.Now()
+	redCtx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	err = cacheError(fillPageScript.Run(redCtx, s.client(), []string{key, variantsKey(indexKey), LeaseKey(key)}, args...).Err())
+	s.observe("ListByPage", EventSet, 1, start, err)
+	return err
+}
@@ -0,0 +1,142 @@
+package scache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/scache"
+	"github.com/daqiancode/scache/scachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func ids(rs []member) []int {
+	r := make([]int, len(rs))
+	for i, v := range rs {
+		r[i] = v.Id
+	}
+	return r
+}
+
+func TestListByPage(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	db := scachetest.NewMemoryDB[member, int]("Id")
+	for i := 1; i <= 5; i++ {
+		assert.Nil(t, db.Create(&member{Id: i, Group: 1}))
+	}
+	ca := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	index := scache.NewIndex("Group", 1)
+	orderBys := scache.NewOrderBys("Id", false)
+	key := ca.PageKey(index, orderBys)
+
+	// the first page is read from db while the list is built
+	rs, err := ca.ListByPage(index, orderBys, 0, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{5, 4}, ids(rs))
+	eventually(t, func() bool { return mr.Exists(key) }, "page list not built")
+	for _, c := range []struct {
+		offset int
+		ids    []int
+	}{{0, []int{5, 4}}, {2, []int{3, 2}}, {4, []int{1}}, {6, []int{}}} {
+		rs, err := ca.ListByPage(index, orderBys, c.offset, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, c.ids, ids(rs), c.offset)
+	}
+
+	// a write to the index deletes the lists of every order
+	assert.Nil(t, ca.Create(&member{Id: 6, Group: 1}))
+	assert.False(t, mr.Exists(key))
+	_, err = ca.ListByPage(index, orderBys, 0, 2)
+	assert.Nil(t, err)
+	eventually(t, func() bool { return mr.Exists(key) }, "page list not rebuilt")
+	rs, err = ca.ListByPage(index, orderBys, 0, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{6, 5}, ids(rs))
+
+	// an empty index is cached too
+	empty := scache.NewIndex("Group", 2)
+	rs, err = ca.ListByPage(empty, nil, 0, 2)
+	assert.Nil(t, err)
+	assert.Empty(t, rs)
+	eventually(t, func() bool { return mr.Exists(ca.PageKey(empty, nil)) }, "empty page list not built")
+	rs, err = ca.ListByPage(empty, nil, 0, 2)
+	assert.Nil(t, err)
+	assert.Empty(t, rs)
+}
+
+func TestListByPageMax(t *testing.T) {
+	maxPageList := scache.MaxPageList
+	scache.MaxPageList = 3
+	defer func() { scache.MaxPageList = maxPageList }()
+	red, mr := scachetest.NewRedis(t)
+	db := scachetest.NewMemoryDB[member, int]("Id")
+	for i := 1; i <= 5; i++ {
+		assert.Nil(t, db.Create(&member{Id: i, Group: 1}))
+	}
+	ca := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	index := scache.NewIndex("Group", 1)
+	orderBys := scache.NewOrderBys("Id", true)
+	key := ca.PageKey(index, orderBys)
+	_, err := ca.ListByPage(index, orderBys, 0, 2)
+	assert.Nil(t, err)
+	eventually(t, func() bool { return mr.Exists(key) }, "page list not built")
+	items, _ := mr.List(key)
+	// header and the first 3 ids
+	assert.Len(t, items, 4)
+	rs, err := ca.ListByPage(index, orderBys, 2, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{3, 4}, ids(rs))
+}
+
+func TestListByCursor(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	db := scachetest.NewMemoryDB[member, int]("Id")
+	for i := 1; i <= 5; i++ {
+		assert.Nil(t, db.Create(&member{Id: i, Group: 1}))
+	}
+	ca := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	index := scache.NewIndex("Group", 1)
+	orderBys := scache.NewOrderBys("Id", true)
+	_, err := ca.ListByPage(index, orderBys, 0, 1)
+	assert.Nil(t, err)
+	eventually(t, func() bool { return mr.Exists(ca.PageKey(index, orderBys)) }, "page list not built")
+
+	var all []int
+	cursor := ""
+	for i := 0; i < 3; i++ {
+		rs, next, err := ca.ListByCursor(index, orderBys, cursor, 2)
+		assert.Nil(t, err)
+		all = append(all, ids(rs)...)
+		cursor = next
+		if next == "" {
+			break
+		}
+	}
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, all)
+	assert.Equal(t, "", cursor)
+
+	for _, c := range []string{"x", "-1", "1.5"} {
+		_, _, err := ca.ListByCursor(index, orderBys, c, 2)
+		assert.ErrorIs(t, err, scache.ErrInvalidCursor, c)
+	}
+}
+
+func TestListByPageVariantsTTL(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	db := scachetest.NewMemoryDB[member, int]("Id")
+	assert.Nil(t, db.Create(&member{Id: 1, Group: 1}))
+	ca := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	ca.SetTTLJitter(30 * time.Second)
+	index := scache.NewIndex("Group", 1)
+	key := ca.PageKey(index, nil)
+	_, err := ca.ListByPage(index, nil, 0, 1)
+	assert.Nil(t, err)
+	eventually(t, func() bool { return mr.Exists(key) }, "page list not built")
+
+	// the variants outlive the page list, whatever its jitter
+	variants := ca.MakeCacheKey(index) + "/variants"
+	members, err := mr.Members(variants)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{key}, members)
+	assert.Equal(t, 90*time.Second, mr.TTL(variants))
+	assert.LessOrEqual(t, mr.TTL(key), 90*time.Second)
+}
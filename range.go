@@ -0,0 +1,431 @@
+package scache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// AddRangeIndex cache the ids of records by every index of ListIndexes in a redis sorted set scored by scoreField, queried by ListByRange.
+// scoreField is an integer, float or time.Time field (or a pointer to them, nil pointers are not indexed), times are scored in unix milliseconds.
+// The sorted sets are built on the first query, and updated by Create, Save, Update and Delete afterwards
+func (s *RedisCache[T, I]) AddRangeIndex(scoreField string) error {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	f, ok := t.FieldByName(scoreField)
+	if !ok {
+		return fmt.Errorf("%w: range index field %s not found in %s", ErrNotSupported, scoreField, t.Name())
+	}
+	if !scoreType(f.Type) {
+		return fmt.Errorf("%w: range index field %s of type %s", ErrNotSupported, scoreField, f.Type)
+	}
+	for _, v := range s.rangeFields {
+		if v == scoreField {
+			return nil
+		}
+	}
+	s.rangeFields = append(s.rangeFields, scoreField)
+	return nil
+}
+
+// RangeKey redis sorted set of the ids of records by index scored by scoreField
+func (s *RedisCache[T, I]) RangeKey(index Index, scoreField string) string {
+	return s.MakeCacheKey(index) + "/range/" + strings.ToLower(scoreField)
+}
+
+func scoreType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return t == timeType
+}
+
+// scoreOf score of an integer, float or time value, ok=false for nil
+func scoreOf(v reflect.Value) (score float64, ok bool, err error) {
+	if !v.IsValid() {
+		return 0, false, nil
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return 0, false, nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true, nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true, nil
+	}
+	if v.Type() == timeType {
+		return float64(v.Interface().(time.Time).UnixMilli()), true, nil
+	}
+	return 0, false, fmt.Errorf("%w: score of type %s", ErrNotSupported, v.Type())
+}
+
+// recordScore score of obj by scoreField, ok=false if the field is nil
+func recordScore[T any](obj T, scoreField string) (float64, bool) {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return 0, false
+		}
+		v = v.Elem()
+	}
+	// the field type is checked by AddRangeIndex
+	score, ok, _ := scoreOf(v.FieldByName(scoreField))
+	return score, ok
+}
+
+// scoreBound score of a range bound, nil means inf
+func scoreBound(bound interface{}, inf float64) (float64, error) {
+	if bound == nil {
+		return inf, nil
+	}
+	score, ok, err := scoreOf(reflect.ValueOf(bound))
+	if !ok && err == nil {
+		return inf, nil
+	}
+	return score, err
+}
+
+// scoreArg redis argument of score
+func scoreArg(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "+inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+// rangeHeader member of every range sorted set scored -inf, so that the sets of empty results exist
+const rangeHeader = ""
+
+// KEYS[1] sorted set key, KEYS[2] lease key.
+// ARGV[1] lease token, empty means fill unconditionally, ARGV[2] ttl in milliseconds, ARGV[3:] score & member pairs
+var fillRangeScript = redis.NewScript(`
+if ARGV[1] ~= '' and redis.call('GET', KEYS[2]) ~= ARGV[1] then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+redis.call('ZADD', KEYS[1], '-inf', '')
+for i = 3, #ARGV, 1000 do
+	redis.call('ZADD', KEYS[1], unpack(ARGV, i, math.min(i + 999, #ARGV)))
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+if ARGV[1] ~= '' then
+	redis.call('DEL', KEYS[2])
+end
+return 1
+`)
+
+// KEYS sorted set keys, ARGV score & member pairs of KEYS, empty score means removing the member.
+// Only cached sets are updated, and their leases are revoked so that readers loading meanwhile can't fill them
+var updateRangeScript = redis.NewScript(`
+for i, k in ipairs(KEYS) do
+	redis.call('DEL', k .. '/lease')
+	if redis.call('EXISTS', k) == 1 then
+		local score, member = ARGV[2 * i - 1], ARGV[2 * i]
+		if score == '' then
+			redis.call('ZREM', k, member)
+		else
+			redis.call('ZADD', k, score, member)
+		end
+	end
+end
+return 1
+`)
+
+// ListByRange list records by index whose scoreField is in [min,max] in ascending order of scoreField, at most limit records, limit<=0 means no limit.
+// min & max are integers, floats or times like scoreField, nil means unbounded. scoreField must be added by AddRangeIndex.
+// With stores not backed by redis records are read from db
+func (s *RedisCache[T, I]) ListByRange(index Index, scoreField string, min, max interface{}, limit int) ([]T, error) {
+	return s.ListByRangeCtx(context.Background(), index, scoreField, min, max, limit)
+}
+
+func (s *RedisCache[T, I]) ListByRangeCtx(ctx context.Context, index Index, scoreField string, min, max interface{}, limit int) ([]T, error) {
+	return s.listByRange(ctx, index, scoreField, min, max, limit, false)
+}
+
+// ListByRangeRev like ListByRange in descending order of scoreField, eg. newest first
+func (s *RedisCache[T, I]) ListByRangeRev(index Index, scoreField string, min, max interface{}, limit int) ([]T, error) {
+	return s.ListByRangeRevCtx(context.Background(), index, scoreField, min, max, limit)
+}
+
+func (s *RedisCache[T, I]) ListByRangeRevCtx(ctx context.Context, index Index, scoreField string, min, max interface{}, limit int) ([]T, error) {
+	return s.listByRange(ctx, index, scoreField, min, max, limit, true)
+}
+
+func (s *RedisCache[T, I]) listByRange(ctx context.Context, index Index, scoreField string, min, max interface{}, limit int, rev bool) ([]T, error) {
+	if !s.hasRangeIndex(scoreField) {
+		return nil, fmt.Errorf("%w: range index %s is not added, call AddRangeIndex first", ErrNotSupported, scoreField)
+	}
+	minScore, err := scoreBound(min, math.Inf(-1))
+	if err != nil {
+		return nil, err
+	}
+	maxScore, err := scoreBound(max, math.Inf(1))
+	if err != nil {
+		return nil, err
+	}
+	s.syncGeneration(ctx)
+	// sorted sets need a redis store
+	if s.client() == nil || !s.cacheAvailable(ctx) {
+		return s.rangeFromDB(ctx, index, scoreField, minScore, maxScore, limit, rev)
+	}
+	key := s.RangeKey(index, scoreField)
+	start := time.Now()
+	ids, found, err := s.rangeIds(ctx, key, minScore, maxScore, limit, rev)
+	if err != nil {
+		s.observe("ListByRange", EventError, 1, start, err)
+		if s.cacheFailed(ctx, err) {
+			return s.rangeFromDB(ctx, index, scoreField, minScore, maxScore, limit, rev)
+		}
+		return nil, err
+	}
+	s.cacheSucceeded()
+	if found {
+		s.observe("ListByRange", EventHit, 1, start, nil)
+		s.touch(ctx, key)
+		return s.ListCtx(ctx, ids...)
+	}
+	s.observe("ListByRange", EventMiss, 1, start, nil)
+	v, err, _ := s.do(ctx, "range:"+key, func(ctx context.Context) (interface{}, error) {
+		return s.buildRange(ctx, index, scoreField, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rs, ok := v.([]T); ok {
+		return filterRange(rs, scoreField, minScore, maxScore, limit, rev), nil
+	}
+	// filled by another reader
+	if ids, found, err := s.rangeIds(ctx, key, minScore, maxScore, limit, rev); err == nil && found {
+		return s.ListCtx(ctx, ids...)
+	}
+	return s.rangeFromDB(ctx, index, scoreField, minScore, maxScore, limit, rev)
+}
+
+func (s *RedisCache[T, I]) hasRangeIndex(scoreField string) bool {
+	for _, v := range s.rangeFields {
+		if v == scoreField {
+			return true
+		}
+	}
+	return false
+}
+
+// rangeIds read ids scored in [min,max] from the sorted set at key, found=false if the set is not cached
+func (s *RedisCache[T, I]) rangeIds(ctx context.Context, key string, min, max float64, limit int, rev bool) ([]I, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	// go-redis swaps min & max for rev
+	args := redis.ZRangeArgs{Key: key, ByScore: true, Start: scoreArg(min), Stop: scoreArg(max), Rev: rev}
+	if limit > 0 {
+		// the header may be in range
+		args.Count = int64(limit) + 1
+	}
+	p := s.client().Pipeline()
+	exists := p.Exists(ctx, key)
+	members := p.ZRangeArgs(ctx, args)
+	if _, err := p.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, false, cacheError(err)
+	}
+	if exists.Val() == 0 {
+		return nil, false, nil
+	}
+	ids := make([]I, 0, len(members.Val()))
+	for _, v := range members.Val() {
+		if v == rangeHeader {
+			continue
+		}
+		var id I
+		if err := json.UnmarshalFromString(v, &id); err != nil {
+			return nil, false, decodeError(err)
+		}
+		ids = append(ids, id)
+	}
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, true, nil
+}
+
+// buildRange load all records by index from db and cache them in the sorted set at key.
+// It returns nil if the set was filled by another reader
+func (s *RedisCache[T, I]) buildRange(ctx context.Context, index Index, scoreField, key string) (interface{}, error) {
+	l, filled, err := s.acquireLease(ctx, key)
+	if err != nil {
+		if !s.cacheFailed(ctx, err) {
+			return nil, err
+		}
+		l = &lease{}
+	}
+	if filled {
+		return nil, nil
+	}
+	start := time.Now()
+	rs, err := s.db.ListByCtx(ctx, index, nil)
+	s.observe("ListByRange", EventDBLoad, len(rs), start, err)
+	if err != nil {
+		return nil, err
+	}
+	if l != nil && l.token == "" {
+		// another reader holds the lease, or redis is unavailable
+		return rs, nil
+	}
+	token := ""
+	if l != nil {
+		token = l.token
+	}
+	args := make([]interface{}, 0, 2*len(rs)+2)
+	args = append(args, token, s.redIds.expiration().Milliseconds())
+	for _, v := range rs {
+		score, ok := recordScore(v, scoreField)
+		if !ok {
+			continue
+		}
+		id, err := json.MarshalToString(v.GetID())
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, scoreArg(score), id)
+	}
+	start = time.Now()
+	redCtx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	err = cacheError(fillRangeScript.Run(redCtx, s.client(), []string{key, LeaseKey(key)}, args...).Err())
+	s.observe("ListByRange", EventSet, 1, start, err)
+	if err != nil && !s.cacheFailed(ctx, err) {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// rangeFromDB list records by index from db and filter them by range
+func (s *RedisCache[T, I]) rangeFromDB(ctx context.Context, index Index, scoreField string, min, max float64, limit int, rev bool) ([]T, error) {
+	rs, err := s.db.ListByCtx(ctx, index, nil)
+	if err != nil {
+		return nil, err
+	}
+	return filterRange(rs, scoreField, min, max, limit, rev), nil
+}
+
+// filterRange records scored in [min,max] in order of score
+func filterRange[T any](rs []T, scoreField string, min, max float64, limit int, rev bool) []T {
+	type scored struct {
+		obj   T
+		score float64
+	}
+	in := make([]scored, 0, len(rs))
+	for _, v := range rs {
+		if score, ok := recordScore(v, scoreField); ok && score >= min && score <= max {
+			in = append(in, scored{v, score})
+		}
+	}
+	sort.SliceStable(in, func(i, j int) bool {
+		if rev {
+			return in[i].score > in[j].score
+		}
+		return in[i].score < in[j].score
+	})
+	if limit > 0 && len(in) > limit {
+		in = in[:limit]
+	}
+	r := make([]T, len(in))
+	for i, v := range in {
+		r[i] = v.obj
+	}
+	return r
+}
+
+// rangeKeys sorted set keys of objs
+func (s *RedisCache[T, I]) rangeKeys(objs ...T) []string {
+	var keys []string
+	for _, field := range s.rangeFields {
+		for _, v := range objs {
+			for _, u := range v.ListIndexes() {
+				keys = append(keys, s.RangeKey(u, field))
+			}
+		}
+	}
+	return UniqueStrings(keys)
+}
+
+// updateRanges remove removed records from cached sorted sets and add added records, after writes.
+// The write succeeded already, the sets are deleted instead on failures
+func (s *RedisCache[T, I]) updateRanges(ctx context.Context, removed, added []T) {
+	// other stores than redis don't cache sorted sets
+	if len(s.rangeFields) == 0 || len(removed)+len(added) == 0 || s.client() == nil {
+		return
+	}
+	var keys []string
+	var args []interface{}
+	for _, field := range s.rangeFields {
+		for _, v := range removed {
+			id, err := json.MarshalToString(v.GetID())
+			if err != nil {
+				continue
+			}
+			for _, u := range v.ListIndexes() {
+				keys = append(keys, s.RangeKey(u, field))
+				args = append(args, "", id)
+			}
+		}
+		for _, v := range added {
+			id, err := json.MarshalToString(v.GetID())
+			if err != nil {
+				continue
+			}
+			score, ok := recordScore(v, field)
+			for _, u := range v.ListIndexes() {
+				keys = append(keys, s.RangeKey(u, field))
+				if ok {
+					args = append(args, scoreArg(score), id)
+				} else {
+					args = append(args, "", id)
+				}
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	if s.cacheAvailable(ctx) {
+		redCtx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+		start := time.Now()
+		err := cacheError(updateRangeScript.Run(redCtx, s.client(), keys, args...).Err())
+		cancel()
+		s.observe("UpdateRange", EventInvalidation, len(keys), start, err)
+		if err == nil {
+			s.cacheSucceeded()
+			return
+		}
+		s.cacheFailed(ctx, err)
+	}
+	keys = UniqueStrings(keys)
+	if err := s.clearOrQueue(ctx, keys); err != nil {
+		s.warn("scache: update range index failed", err, "keys", keys)
+	}
+}
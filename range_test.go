@@ -0,0 +1,106 @@
+package scache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/daqiancode/scache"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+type rangeOrder struct {
+	Id        int
+	Uid       int
+	CreatedAt time.Time
+	Name      string
+}
+
+func (s rangeOrder) GetID() int { return s.Id }
+func (s rangeOrder) ListIndexes() scache.Indexes {
+	return scache.Indexes{scache.NewIndex("Uid", s.Uid)}
+}
+
+// rangeDB serves ListBy only
+type rangeDB struct {
+	scache.DBCRUD[rangeOrder, int]
+	rows []rangeOrder
+}
+
+func (s *rangeDB) Close() error {
+	return nil
+}
+
+func (s *rangeDB) ListByCtx(ctx context.Context, index scache.Index, orderBys scache.OrderBys) ([]rangeOrder, error) {
+	var r []rangeOrder
+	for _, v := range s.rows {
+		if v.Uid == index["Uid"] {
+			r = append(r, v)
+		}
+	}
+	return r, nil
+}
+
+func (s *rangeDB) ListCtx(ctx context.Context, ids ...int) ([]rangeOrder, error) {
+	var r []rangeOrder
+	for _, id := range ids {
+		for _, v := range s.rows {
+			if v.Id == id {
+				r = append(r, v)
+			}
+		}
+	}
+	return r, nil
+}
+
+func TestListByRange(t *testing.T) {
+	// nothing listens on port 1, records are filtered from db
+	red := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	db := &rangeDB{rows: []rangeOrder{
+		{Id: 1, Uid: 1, CreatedAt: t0},
+		{Id: 2, Uid: 1, CreatedAt: t0.Add(2 * time.Hour)},
+		{Id: 3, Uid: 2, CreatedAt: t0.Add(time.Hour)},
+		{Id: 4, Uid: 1, CreatedAt: t0.Add(time.Hour)},
+	}}
+	ca := scache.NewRedisCache[rangeOrder, int]("test", "order", "Id", db, red, time.Minute)
+	assert.True(t, errors.Is(ca.AddRangeIndex("Name"), scache.ErrNotSupported))
+	assert.True(t, errors.Is(ca.AddRangeIndex("Missing"), scache.ErrNotSupported))
+	_, err := ca.ListByRange(scache.NewIndex("Uid", 1), "CreatedAt", nil, nil, 0)
+	assert.True(t, errors.Is(err, scache.ErrNotSupported))
+
+	assert.Nil(t, ca.AddRangeIndex("CreatedAt"))
+	ca.EnableFailOpen(scache.FailOpenOptions{Threshold: 1, ProbeInterval: time.Hour})
+	rs, err := ca.ListByRange(scache.NewIndex("Uid", 1), "CreatedAt", t0, t0.Add(time.Hour), 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []rangeOrder{db.rows[0], db.rows[3]}, rs)
+	rs, err = ca.ListByRangeRev(scache.NewIndex("Uid", 1), "CreatedAt", t0.Add(time.Minute), nil, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []rangeOrder{db.rows[1]}, rs)
+	assert.Nil(t, ca.Close())
+}
+
+func TestListByRangeRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	red := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	db := &rangeDB{}
+	for i := 1; i <= 5; i++ {
+		db.rows = append(db.rows, rangeOrder{Id: i, Uid: 1, CreatedAt: t0.Add(time.Duration(i) * time.Hour)})
+	}
+	ca := scache.NewRedisCache[rangeOrder, int]("test", "order", "Id", db, red, time.Minute)
+	assert.Nil(t, ca.AddRangeIndex("CreatedAt"))
+	// the first query builds the sorted set, the second one reads it
+	for i := 0; i < 2; i++ {
+		rs, err := ca.ListByRangeRev(scache.NewIndex("Uid", 1), "CreatedAt", t0.Add(2*time.Hour), t0.Add(4*time.Hour), 2)
+		assert.Nil(t, err)
+		assert.Equal(t, []rangeOrder{db.rows[3], db.rows[2]}, rs)
+		rs, err = ca.ListByRange(scache.NewIndex("Uid", 1), "CreatedAt", t0.Add(2*time.Hour), t0.Add(4*time.Hour), 2)
+		assert.Nil(t, err)
+		assert.Equal(t, []rangeOrder{db.rows[1], db.rows[2]}, rs)
+	}
+	assert.True(t, mr.Exists(ca.RangeKey(scache.NewIndex("Uid", 1), "CreatedAt")))
+}
@@ -4,9 +4,14 @@ import (
 	"context"
 	"errors"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 )
 
 var ErrRecordNotFound = errors.New("record not exist")
@@ -16,17 +21,54 @@ type RedisCache[T Table[I], I IDType] struct {
 	red    *RedisJson[T]
 	redId  *RedisJson[I]   // unique index,1 index to 1 id
 	redIds *RedisJson[[]I] // normal index, 1 index to multple ids
-	db     DBCRUD[T, I]
+	// redCount number of records by index, not in local cache
+	redCount *RedisJson[int64]
+	db       DBCRUD[T, I]
+	// sf coalesce concurrent db loads of the same cache key
+	sf singleflight.Group
+	// local optional in-process cache in front of redis
+	local LocalCache
+	// invalidator publish cleared keys to other instances
+	invalidator *Invalidator
+	// generationRefresh reload interval of the table generation, 0 means generation is disabled
+	generationRefresh time.Duration
+	// generationSyncedAt unix nano of the last generation reload
+	generationSyncedAt int64
+	// delayedDelete delay of the second cache deletion after writes, 0 means disabled
+	delayedDelete     time.Duration
+	delayedDeleteHook func(keys []string, err error)
+	// timers pending delayed deletions, stopped by Close
+	timersMu sync.Mutex
+	timers   map[*time.Timer]struct{}
+	closed   bool
+	// leaseOpts nil means leases are disabled
+	leaseOpts *LeaseOptions
+	// breaker nil means fail open is disabled
+	breaker *breaker
+	// retry nil means failed invalidations are not retried
+	retry *RetryQueue
+	// rangeFields score fields of range indexes
+	rangeFields []string
 }
 
-func NewRedisCache[T Table[I], I IDType](prefix, table, idField string, db DBCRUD[T, I], red *redis.Client, ttl time.Duration) *RedisCache[T, I] {
-	return &RedisCache[T, I]{
-		CacheBase: &CacheBase[T, I]{prefix: prefix, table: table, idField: idField},
-		red:       NewRedisJson[T](red, ttl),
-		redId:     NewRedisJson[I](red, ttl),
-		redIds:    NewRedisJson[[]I](red, ttl),
+func NewRedisCache[T Table[I], I IDType](prefix, table, idField string, db DBCRUD[T, I], red *redis.Client, ttl time.Duration, opts ...Option) *RedisCache[T, I] {
+	return NewRedisCacheWithStore(prefix, table, idField, db, NewRedisStore(red), ttl, opts...)
+}
+
+// NewRedisCacheWithStore like NewRedisCache, entries are kept in store, eg. NewMemoryStore() for tests and single process tools
+func NewRedisCacheWithStore[T Table[I], I IDType](prefix, table, idField string, db DBCRUD[T, I], store Store, ttl time.Duration, opts ...Option) *RedisCache[T, I] {
+	r := &RedisCache[T, I]{
+		CacheBase: &CacheBase[T, I]{prefix: prefix, table: table, idField: idFieldOf[T](idField)},
+		red:       NewRedisJsonWithStore[T](store, ttl),
+		redId:     NewRedisJsonWithStore[I](store, ttl),
+		redIds:    NewRedisJsonWithStore[[]I](store, ttl),
+		redCount:  NewRedisJsonWithStore[int64](store, ttl),
 		db:        db,
 	}
+	if o := newOptions(opts); o.serializer != nil {
+		r.SetSerializer(o.serializer)
+	}
+	return r
 }
 
 // func (s *RedisCache[T, I]) SetDB(db DBCRUD[T, I]) {
@@ -36,13 +78,113 @@ func NewRedisCache[T Table[I], I IDType](prefix, table, idField string, db DBCRU
 func (s *RedisCache[T, I]) GetDB() DBCRUD[T, I] {
 	return s.db
 }
+
+func (s *RedisCache[T, I]) GetStore() Store {
+	return s.red.store
+}
+
+// client redis client of the store, nil if the store is not backed by redis
+func (s *RedisCache[T, I]) client() *redis.Client {
+	return redisClient(s.red.store)
+}
+
+// SetSerializer change the encoding of cached objects, default is JsonSerializer
+func (s *RedisCache[T, I]) SetSerializer(serializer Serializer) {
+	s.red.SetSerializer(serializer)
+	s.redId.SetSerializer(serializer)
+	s.redIds.SetSerializer(serializer)
+	s.redCount.SetSerializer(serializer)
+}
+func (s *RedisCache[T, I]) GetSerializer() Serializer {
+	return s.red.GetSerializer()
+}
+
+// SetTTLJitter add a random duration in [0,jitter) to every ttl written, so that records cached together don't expire together
+func (s *RedisCache[T, I]) SetTTLJitter(jitter time.Duration) {
+	s.red.SetTTLJitter(jitter)
+	s.redId.SetTTLJitter(jitter)
+	s.redIds.SetTTLJitter(jitter)
+	s.redCount.SetTTLJitter(jitter)
+}
+
+// SetNullTTL ttl of cached not found records, usually shorter than ttl so that new records show up soon. 0 means ttl
+func (s *RedisCache[T, I]) SetNullTTL(ttl time.Duration) {
+	s.red.SetNullTTL(ttl)
+	s.redId.SetNullTTL(ttl)
+	s.redIds.SetNullTTL(ttl)
+}
+
+// SetLocalCache enable an in-process cache checked before redis, eg. SetLocalCache(NewLRUCache(10000, 5*time.Second)), nil to disable
+func (s *RedisCache[T, I]) SetLocalCache(local LocalCache) {
+	s.local = local
+	s.red.SetLocalCache(local)
+	s.redId.SetLocalCache(local)
+	s.redIds.SetLocalCache(local)
+	if s.invalidator != nil {
+		s.invalidator.SetLocalCache(local)
+	}
+}
+
+// SetLogger report swallowed errors to logger, nil to disable, which is the default
+func (s *RedisCache[T, I]) SetLogger(logger Logger) {
+	s.CacheBase.SetLogger(logger)
+	if s.invalidator != nil {
+		s.invalidator.SetLogger(logger)
+	}
+}
+func (s *RedisCache[T, I]) GetLocalCache() LocalCache {
+	return s.local
+}
+
+// InvalidationChannel redis channel for cleared keys, shared by the tables with the same prefix
+func (s *RedisCache[T, I]) InvalidationChannel() string {
+	return s.prefix + "/invalidation"
+}
+
+// EnableInvalidation publish keys cleared by ClearCache on InvalidationChannel,
+// and evict local cache entries on keys published by other instances. Later SetLocalCache & SetLogger apply to it too.
+// Stores not backed by redis are in-process, it does nothing with them
+func (s *RedisCache[T, I]) EnableInvalidation() {
+	if s.invalidator != nil || s.client() == nil {
+		return
+	}
+	s.invalidator = NewInvalidator(s.client(), s.InvalidationChannel(), s.local)
+	// a purge may come from InvalidateAll, reload generation on next call
+	s.invalidator.SetPurgeHook(func() { atomic.StoreInt64(&s.generationSyncedAt, 0) })
+	s.invalidator.SetLogger(s.logger)
+	s.invalidator.Start()
+}
 func (s *RedisCache[T, I]) Close() error {
+	s.timersMu.Lock()
+	s.closed = true
+	for timer := range s.timers {
+		timer.Stop()
+	}
+	s.timers = nil
+	s.timersMu.Unlock()
+	if s.invalidator != nil {
+		s.invalidator.Close()
+	}
+	if s.retry != nil {
+		s.retry.Close()
+	}
 	return s.db.Close()
 }
 func (s *RedisCache[T, I]) ClearCache(objs ...T) error {
+	return s.ClearCacheCtx(context.Background(), objs...)
+}
+
+func (s *RedisCache[T, I]) ClearCacheCtx(ctx context.Context, objs ...T) error {
 	if len(objs) == 0 {
 		return nil
 	}
+	s.reloadGeneration(ctx)
+	// range indexes are updated by writes, but objs may be changed without the cache
+	return s.clearOrQueue(ctx, append(s.cacheKeys(objs...), s.rangeKeys(objs...)...))
+}
+
+// cacheKeys redis keys of objs: primary key and index keys
+func (s *RedisCache[T, I]) cacheKeys(objs ...T) []string {
 	var keys []string
 	for _, v := range objs {
 		keys = append(keys, s.MakeCacheKey(NewIndex(s.GetIdField(), v.GetID())))
@@ -50,11 +192,144 @@ func (s *RedisCache[T, I]) ClearCache(objs ...T) error {
 			keys = append(keys, s.MakeCacheKey(u))
 		}
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), RdisOpTimeout)
+	return UniqueStrings(keys)
+}
+
+// KEYS keys to delete with their counts, their variants and the leases of the variants, ARGV[1] '1' to delete the leases of keys & counts too.
+// It returns the deleted variants
+var delKeysScript = redis.NewScript(`
+local deleted = {}
+for _, k in ipairs(KEYS) do
+	local vk = k .. '/variants'
+	for _, v in ipairs(redis.call('SMEMBERS', vk)) do
+		redis.call('DEL', v, v .. '/lease')
+		deleted[#deleted + 1] = v
+	end
+	redis.call('DEL', k, vk, k .. '/count')
+	if ARGV[1] == '1' then
+		redis.call('DEL', k .. '/lease', k .. '/count/lease')
+	end
+end
+return deleted
+`)
+
+// deleteKeys delete keys like delKeysScript, by the script on redis stores, key by key on other stores.
+// It returns the deleted variants
+func deleteKeys(ctx context.Context, store Store, keys []string, revokeLeases bool) ([]string, error) {
+	if client := redisClient(store); client != nil {
+		revoke := "0"
+		if revokeLeases {
+			revoke = "1"
+		}
+		return delKeysScript.Run(ctx, client, keys, revoke).StringSlice()
+	}
+	var deleted, dels []string
+	for _, k := range keys {
+		variants, err := store.SMembers(ctx, variantsKey(k))
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range variants {
+			dels = append(dels, v, LeaseKey(v))
+		}
+		deleted = append(deleted, variants...)
+		dels = append(dels, k, variantsKey(k), k+"/count")
+		if revokeLeases {
+			dels = append(dels, LeaseKey(k), LeaseKey(k+"/count"))
+		}
+	}
+	return deleted, store.Del(ctx, dels...)
+}
+
+// delKeys delete keys and their variants from redis & local caches
+func (s *RedisCache[T, I]) delKeys(ctx context.Context, keys []string) error {
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
 	defer cancel()
-	keys = UniqueStrings(keys)
-	return s.red.Del(ctx, keys...).Err()
+	start := time.Now()
+	// revoke leases, readers which loaded before this deletion can't fill
+	variants, err := deleteKeys(ctx, s.red.store, keys, s.leaseOpts != nil)
+	err = cacheError(err)
+	s.observe("ClearCache", EventInvalidation, len(keys), start, err)
+	// variants may be cached locally too, eg. id lists of ListBy in other orders
+	keys = append(keys[:len(keys):len(keys)], variants...)
+	// evict local entries after redis, otherwise they may be refilled from stale redis entries
+	if s.local != nil {
+		s.local.Del(keys...)
+	}
+	if s.invalidator != nil {
+		if errPub := s.invalidator.Publish(ctx, keys...); err == nil {
+			err = errPub
+		}
+	}
+	return err
+}
+
+// SetDelayedDelete clear the cache of written records again after delay, in background.
+// It removes stale records filled by concurrent readers which loaded the old row before the write.
+// onError is called when the second deletion fails, delay<=0 disables it. Close drops the pending deletions
+func (s *RedisCache[T, I]) SetDelayedDelete(delay time.Duration, onError func(keys []string, err error)) {
+	s.delayedDelete = delay
+	s.delayedDeleteHook = onError
+}
+
+// clearAfterWrite clear cache of written objs, and schedule the delayed second deletion.
+// The write succeeded already, failures are logged only
+func (s *RedisCache[T, I]) clearAfterWrite(ctx context.Context, objs ...T) {
+	if len(objs) == 0 {
+		return
+	}
+	s.reloadGeneration(ctx)
+	keys := s.cacheKeys(objs...)
+	if err := s.clearOrQueue(ctx, keys); err != nil {
+		s.warn("scache: clear cache after write failed", err, "keys", keys)
+	}
+	if s.delayedDelete > 0 {
+		s.scheduleDelete(keys)
+	}
+}
+
+// scheduleDelete delete keys again after the delay, pending deletions are dropped by Close
+func (s *RedisCache[T, I]) scheduleDelete(keys []string) {
+	hook := s.delayedDeleteHook
+	s.timersMu.Lock()
+	defer s.timersMu.Unlock()
+	if s.closed {
+		return
+	}
+	if s.timers == nil {
+		s.timers = make(map[*time.Timer]struct{})
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(s.delayedDelete, func() {
+		s.timersMu.Lock()
+		delete(s.timers, timer)
+		s.timersMu.Unlock()
+		// the request context may be done already
+		if err := s.clearOrQueue(context.Background(), keys); err != nil {
+			s.warn("scache: delayed delete failed", err, "keys", keys)
+			if hook != nil {
+				hook(keys, err)
+			}
+		}
+	})
+	s.timers[timer] = struct{}{}
+}
 
+// touch refresh ttl of keys, failures are logged only
+func (s *RedisCache[T, I]) touch(ctx context.Context, keys ...string) {
+	if err := s.red.ExpiresCtx(ctx, keys...); err != nil {
+		s.warn("scache: refresh ttl failed", err, "keys", keys)
+	}
+}
+
+// touchVariant refresh the ttl of an ordered list, and of the variants of indexKey to outlive it
+func (s *RedisCache[T, I]) touchVariant(ctx context.Context, indexKey, key string) {
+	ttls := map[string]time.Duration{key: s.redIds.expiration(), variantsKey(indexKey): s.redIds.ttl + s.redIds.jitter}
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	if err := s.red.store.Expire(ctx, ttls); err != nil {
+		s.warn("scache: refresh ttl failed", cacheError(err), "keys", []string{key, variantsKey(indexKey)})
+	}
 }
 
 // func (s *RedisCache[T, I]) ClearCacheRaw(id I, indexes Indexes) error {
@@ -70,89 +345,175 @@ func (s *RedisCache[T, I]) ClearCache(objs ...T) error {
 // }
 
 func (s *RedisCache[T, I]) Create(obj *T) error {
-	if err := s.db.Create(obj); err != nil {
+	return s.CreateCtx(context.Background(), obj)
+}
+
+func (s *RedisCache[T, I]) CreateCtx(ctx context.Context, obj *T) error {
+	if err := s.db.CreateCtx(ctx, obj); err != nil {
 		return err
 	}
-	s.ClearCache(*obj)
+	s.clearAfterWrite(ctx, *obj)
+	s.updateRanges(ctx, nil, []T{*obj})
 	// s.ClearCache((*obj).GetID(), (*obj).ListIndexes())
 	return nil
 }
 func (s *RedisCache[T, I]) Delete(ids ...I) (int64, error) {
-	objs, err := s.List(ids...)
+	return s.DeleteCtx(context.Background(), ids...)
+}
+
+func (s *RedisCache[T, I]) DeleteCtx(ctx context.Context, ids ...I) (int64, error) {
+	objs, err := s.ListCtx(ctx, ids...)
 	if err != nil {
 		return 0, err
 	}
-	rowsAffected, err := s.db.Delete(ids...)
+	rowsAffected, err := s.db.DeleteCtx(ctx, ids...)
 	if err != nil {
 		return 0, err
 	}
-	s.ClearCache(objs...)
+	s.clearAfterWrite(ctx, objs...)
+	s.updateRanges(ctx, objs, nil)
 	// for _, v := range objs {
 	// 	err = s.ClearCache(v.GetID(), v.ListIndexes())
 	// }
 	return rowsAffected, err
 }
 func (s *RedisCache[T, I]) Save(obj *T) error {
-	old, err := s.Get((*obj).GetID())
+	return s.SaveCtx(context.Background(), obj)
+}
+
+func (s *RedisCache[T, I]) SaveCtx(ctx context.Context, obj *T) error {
+	old, err := s.GetCtx(ctx, (*obj).GetID())
 	if err != nil && err != ErrRecordNotFound {
 		return err
 	}
+	var removed []T
 	if IsNullID((*obj).GetID()) || err == ErrRecordNotFound {
-		if err := s.db.Create(obj); err != nil {
+		if err := s.db.CreateCtx(ctx, obj); err != nil {
 			return err
 		}
 	} else {
-		if err := s.db.Save(obj); err != nil {
+		if err := s.db.SaveCtx(ctx, obj); err != nil {
 			return err
 		}
+		removed = []T{old}
 	}
-	s.ClearCache(old, *obj)
+	s.clearAfterWrite(ctx, old, *obj)
+	s.updateRanges(ctx, removed, []T{*obj})
 	return nil
 }
 
 // Update values can be struct or map[string]interface{}
 func (s *RedisCache[T, I]) Update(id I, values interface{}) (int64, error) {
+	return s.UpdateCtx(context.Background(), id, values)
+}
+
+// UpdateCtx values can be struct or map[string]interface{}
+func (s *RedisCache[T, I]) UpdateCtx(ctx context.Context, id I, values interface{}) (int64, error) {
 	if IsNullID(id) {
 		return 0, nil
 	}
-	old, err := s.Get(id)
+	old, err := s.GetCtx(ctx, id)
 	if err != nil {
 		return 0, err
 	}
-	effectedRows, err := s.db.Update(id, values)
+	effectedRows, err := s.db.UpdateCtx(ctx, id, values)
 	if err != nil {
 		return 0, err
 	}
 
-	obj, err := s.db.Get(id)
-	s.ClearCache(old, obj)
+	obj, err := s.db.GetCtx(ctx, id)
+	s.clearAfterWrite(ctx, old, obj)
+	if err == nil {
+		s.updateRanges(ctx, []T{old}, []T{obj})
+	} else {
+		s.updateRanges(ctx, []T{old}, nil)
+	}
 	// err = s.ClearCache(old.GetID(), old.ListIndexes().Merge(obj.ListIndexes()))
 	return effectedRows, err
 }
 
 func (s *RedisCache[T, I]) Get(id I) (T, error) {
+	return s.GetCtx(context.Background(), id)
+}
+
+func (s *RedisCache[T, I]) GetCtx(ctx context.Context, id I) (T, error) {
+	s.syncGeneration(ctx)
 	redisKey := s.MakeCacheKey(NewIndex(s.GetIdField(), id))
-	r, err := s.red.GetJson(redisKey)
+	if !s.cacheAvailable(ctx) {
+		return s.db.GetCtx(ctx, id)
+	}
+	start := time.Now()
+	r, h, err := s.red.getJsonCtx(ctx, redisKey)
 	if err != nil && err != redis.Nil {
+		s.observe("Get", EventError, 1, start, err)
+		if s.cacheFailed(ctx, err) {
+			return s.db.GetCtx(ctx, id)
+		}
 		return r, err
 	}
+	s.cacheSucceeded()
 	if err == nil {
-		s.red.Expires(redisKey)
+		s.observeHit("Get", h, start)
+		// null entries keep their own ttl
+		if !h.local && !h.null {
+			s.touch(ctx, redisKey)
+		}
+		if h.stale {
+			s.revalidate(redisKey, func(ctx context.Context, l *lease) error {
+				_, err := s.load(ctx, id, redisKey, l)
+				return err
+			})
+		}
+		if h.null {
+			return r, ErrRecordNotFound
+		}
 		return r, nil
 	}
-	r, err = s.db.Get(id)
+	s.observe("Get", EventMiss, 1, start, nil)
+	v, err, _ := s.do(ctx, "get:"+redisKey, func(ctx context.Context) (interface{}, error) {
+		l, filled, err := s.acquireLease(ctx, redisKey)
+		if err != nil {
+			if !s.cacheFailed(ctx, err) {
+				return r, err
+			}
+			// load without filling
+			l = &lease{}
+		}
+		if filled {
+			if r, h, err := s.red.getJsonCtx(ctx, redisKey); err == nil {
+				if h.null {
+					return r, ErrRecordNotFound
+				}
+				return r, nil
+			}
+		}
+		return s.load(ctx, id, redisKey, l)
+	})
+	r, _ = v.(T)
+	return r, err
+}
+
+// load fetch record from db and fill it into redis
+func (s *RedisCache[T, I]) load(ctx context.Context, id I, redisKey string, l *lease) (T, error) {
+	start := time.Now()
+	r, err := s.db.GetCtx(ctx, id)
 	if err != nil && err != ErrRecordNotFound {
+		s.observe("Get", EventDBLoad, 1, start, err)
 		return r, err
 	}
+	s.observe("Get", EventDBLoad, 1, start, nil)
+	start = time.Now()
 	if err == ErrRecordNotFound {
-		errSet := s.red.SetNull(redisKey)
-		if errSet != nil {
+		errSet := s.red.setNullLeased(ctx, redisKey, l)
+		s.observe("Get", EventSet, 1, start, errSet)
+		if errSet != nil && !s.cacheFailed(ctx, errSet) {
 			return r, errSet
 		}
 		return r, err
 	}
-	errSet := s.red.SetJson(redisKey, r)
-	if errSet != nil {
+	errSet := s.red.setJsonLeased(ctx, redisKey, r, l)
+	s.observe("Get", EventSet, 1, start, errSet)
+	if errSet != nil && !s.cacheFailed(ctx, errSet) {
 		return r, errSet
 	}
 	return r, err
@@ -160,17 +521,48 @@ func (s *RedisCache[T, I]) Get(id I) (T, error) {
 
 // List list records by ids, order & empty records keeped
 func (s *RedisCache[T, I]) List(ids ...I) ([]T, error) {
+	return s.ListCtx(context.Background(), ids...)
+}
+
+// ListCtx list records by ids, order & empty records keeped
+func (s *RedisCache[T, I]) ListCtx(ctx context.Context, ids ...I) ([]T, error) {
+	s.syncGeneration(ctx)
 	// fetch records from redis by ids
 	redisKeys := make([]string, len(ids))
 	for i, v := range ids {
 		redisKeys[i] = s.MakeCacheKey(NewIndex(s.GetIdField(), v))
 	}
-	cachedRecords, missedIndexes, err := s.red.MGetJson(redisKeys)
+	if !s.cacheAvailable(ctx) {
+		return s.listFromDB(ctx, ids)
+	}
+	// MGetJsonCtx refreshes ttl of the keys fetched from redis
+	start := time.Now()
+	cachedRecords, missedIndexes, staleIndexes, err := s.red.mGetJsonCtx(ctx, redisKeys)
 	if err != nil {
+		s.observe("List", EventError, len(ids), start, err)
+		if s.cacheFailed(ctx, err) {
+			return s.listFromDB(ctx, ids)
+		}
 		return nil, err
 	}
+	s.cacheSucceeded()
+	if s.observer != nil {
+		nulls := -len(missedIndexes)
+		for _, v := range cachedRecords {
+			if IsNullID(v.GetID()) {
+				nulls++
+			}
+		}
+		s.observeBatch("List", len(ids), len(missedIndexes), nulls, start)
+	}
+	if len(staleIndexes) > 0 {
+		staleIds := make([]I, len(staleIndexes))
+		for i, v := range staleIndexes {
+			staleIds[i] = ids[v]
+		}
+		s.revalidateList(staleIds)
+	}
 	if len(missedIndexes) == 0 {
-		s.red.Expires(redisKeys...)
 		return cachedRecords, err
 	}
 	cachedIdIndexMap := make(map[I]bool, len(cachedRecords))
@@ -196,84 +588,263 @@ func (s *RedisCache[T, I]) List(ids ...I) ([]T, error) {
 	// if len(missedIds) == 0 {
 	// 	return cachedRecords, nil
 	// }
-	// search missed record from database
-	var missedRecords []T
-	missedRecords, err = s.db.List(missedIds...)
+	// search missed record from database, concurrent lists missing the same ids share one query
+	missedKeys := make([]string, len(missedIndexes))
+	for i, v := range missedIndexes {
+		missedKeys[i] = redisKeys[v]
+	}
+	sort.Strings(missedKeys)
+	missedRecords, err, _ := s.do(ctx, "list:"+strings.Join(missedKeys, ","), func(ctx context.Context) (interface{}, error) {
+		return s.loadList(ctx, missedIds)
+	})
 	if err != nil {
 		return cachedRecords, err
 	}
+	for _, v := range missedRecords.([]T) {
+		cachedRecords[missedIndexes[missedIdIndexMap[v.GetID()]]] = v
+	}
+	return cachedRecords, nil
+}
+
+// loadList fetch missed records from db and fill them into redis, ids not in db are cached as null
+func (s *RedisCache[T, I]) loadList(ctx context.Context, missedIds []I) ([]T, error) {
+	start := time.Now()
+	missedRecords, err := s.db.ListCtx(ctx, missedIds...)
+	s.observe("List", EventDBLoad, len(missedIds), start, err)
+	if err != nil {
+		return nil, err
+	}
 	needToCache := make(map[string]interface{}, len(missedRecords))
-	needToCacheNull := make([]string, len(missedIds)-len(missedRecords))
+	needToCacheNull := make([]string, 0, len(missedIds))
 
 	//数据库中存在的id
 	dbIds := make(map[I]bool)
 	for _, v := range missedRecords {
 		needToCache[s.MakeCacheKey(NewIndex(s.GetIdField(), v.GetID()))] = v
-		cachedRecords[missedIdIndexMap[v.GetID()]] = v
 		dbIds[v.GetID()] = true
 	}
 	//数据库中不存在的objs
-	i := 0
 	for _, v := range missedIds {
 		if !dbIds[v] {
-			needToCacheNull[i] = s.MakeCacheKey(NewIndex(s.GetIdField(), v))
-			i++
+			needToCacheNull = append(needToCacheNull, s.MakeCacheKey(NewIndex(s.GetIdField(), v)))
 		}
 	}
-	s.red.MSetJson(needToCache)
-	s.red.MSetNull(needToCacheNull)
-	return cachedRecords, nil
+	start = time.Now()
+	err = s.red.MSetJsonCtx(ctx, needToCache)
+	if errNull := s.red.MSetNullCtx(ctx, needToCacheNull); err == nil {
+		err = errNull
+	}
+	s.observe("List", EventSet, len(missedIds), start, err)
+	if err != nil {
+		s.warn("scache: fill cache failed", err, "ids", missedIds)
+	}
+	return missedRecords, nil
 }
 
 func (s *RedisCache[T, I]) GetBy(index Index) (T, error) {
+	return s.GetByCtx(context.Background(), index)
+}
+
+func (s *RedisCache[T, I]) GetByCtx(ctx context.Context, index Index) (T, error) {
+	s.syncGeneration(ctx)
 	// fetch id from redis
 	redisKey := s.MakeCacheKey(index)
 	var r T
-	cachedId, err := s.redId.GetJson(redisKey)
+	if !s.cacheAvailable(ctx) {
+		return s.db.GetByCtx(ctx, index)
+	}
+	start := time.Now()
+	cachedId, h, err := s.redId.getJsonCtx(ctx, redisKey)
 	if err != nil && err != redis.Nil {
+		s.observe("GetBy", EventError, 1, start, err)
+		if s.cacheFailed(ctx, err) {
+			return s.db.GetByCtx(ctx, index)
+		}
 		return r, err
 	}
+	s.cacheSucceeded()
+	if err == nil {
+		s.observeHit("GetBy", h, start)
+	} else {
+		s.observe("GetBy", EventMiss, 1, start, nil)
+	}
+	if err == nil && h.stale {
+		s.revalidate(redisKey, func(ctx context.Context, l *lease) error {
+			_, err := s.loadBy(ctx, index, redisKey, l)
+			return err
+		})
+	}
 	if err == nil && IsNullID(cachedId) {
 		return r, ErrRecordNotFound
 	}
 	if err == nil {
-		s.red.Expires(redisKey)
-		return s.Get(cachedId)
-	}
+		if !h.local {
+			s.touch(ctx, redisKey)
+		}
+		return s.GetCtx(ctx, cachedId)
+	}
+	v, err, _ := s.do(ctx, "getby:"+redisKey, func(ctx context.Context) (interface{}, error) {
+		l, filled, err := s.acquireLease(ctx, redisKey)
+		if err != nil {
+			if !s.cacheFailed(ctx, err) {
+				return r, err
+			}
+			l = &lease{}
+		}
+		if filled {
+			if cachedId, _, err := s.redId.getJsonCtx(ctx, redisKey); err == nil {
+				if IsNullID(cachedId) {
+					return r, ErrRecordNotFound
+				}
+				return s.GetCtx(ctx, cachedId)
+			}
+		}
+		return s.loadBy(ctx, index, redisKey, l)
+	})
+	r, _ = v.(T)
+	return r, err
+}
+
+// loadBy fetch record by index from db and fill its id into redis
+func (s *RedisCache[T, I]) loadBy(ctx context.Context, index Index, redisKey string, l *lease) (T, error) {
 	// search from db
-	r, err = s.db.GetBy(index)
+	start := time.Now()
+	r, err := s.db.GetByCtx(ctx, index)
+	if err != nil && err != ErrRecordNotFound {
+		s.observe("GetBy", EventDBLoad, 1, start, err)
+		return r, err
+	}
+	s.observe("GetBy", EventDBLoad, 1, start, nil)
+	start = time.Now()
 	if err == ErrRecordNotFound {
-		errSet := s.red.SetNull(redisKey)
-		if errSet != nil {
+		errSet := s.redId.setNullLeased(ctx, redisKey, l)
+		s.observe("GetBy", EventSet, 1, start, errSet)
+		if errSet != nil && !s.cacheFailed(ctx, errSet) {
 			return r, errSet
 		}
 		return r, err
 	}
-	if err != nil {
-		return r, err
-	}
 
 	// set id to redis
-	errSet := s.redId.SetJson(redisKey, r.GetID())
-	if errSet != nil {
+	errSet := s.redId.setJsonLeased(ctx, redisKey, r.GetID(), l)
+	s.observe("GetBy", EventSet, 1, start, errSet)
+	if errSet != nil && !s.cacheFailed(ctx, errSet) {
 		return r, errSet
 	}
 	return r, err
 }
 func (s *RedisCache[T, I]) ListBy(index Index, orderBys OrderBys) ([]T, error) {
+	return s.ListByCtx(context.Background(), index, orderBys)
+}
+
+func (s *RedisCache[T, I]) ListByCtx(ctx context.Context, index Index, orderBys OrderBys) ([]T, error) {
+	s.syncGeneration(ctx)
 	// fetch ids from redis
-	redisKey := s.MakeCacheKey(index)
+	redisKey := s.ListByKey(index, orderBys)
 	var r []T
-	cachedIds, err := s.redIds.GetJson(redisKey)
+	if !s.cacheAvailable(ctx) {
+		return s.db.ListByCtx(ctx, index, orderBys)
+	}
+	start := time.Now()
+	cachedIds, h, err := s.redIds.getJsonCtx(ctx, redisKey)
 	if err != nil && err != redis.Nil {
+		s.observe("ListBy", EventError, 1, start, err)
+		if s.cacheFailed(ctx, err) {
+			return s.db.ListByCtx(ctx, index, orderBys)
+		}
 		return nil, err
 	}
+	s.cacheSucceeded()
 	if err == nil {
-		s.red.Expires(redisKey)
-		return s.List(cachedIds...)
+		s.observeHit("ListBy", h, start)
+		if !h.local {
+			if indexKey := s.MakeCacheKey(index); redisKey != indexKey {
+				s.touchVariant(ctx, indexKey, redisKey)
+			} else {
+				s.touch(ctx, redisKey)
+			}
+		}
+		if h.stale {
+			s.revalidate(redisKey, func(ctx context.Context, l *lease) error {
+				_, err := s.loadListBy(ctx, index, orderBys, redisKey, l)
+				return err
+			})
+		}
+		return s.ListCtx(ctx, cachedIds...)
+	}
+	s.observe("ListBy", EventMiss, 1, start, nil)
+	v, err, shared := s.do(ctx, "listby:"+redisKey, func(ctx context.Context) (interface{}, error) {
+		// register the ordered list before loading, so that ClearCache revokes its lease
+		err := s.registerVariant(ctx, index, redisKey)
+		var l *lease
+		var filled bool
+		if err == nil {
+			l, filled, err = s.acquireLease(ctx, redisKey)
+		}
+		if err != nil {
+			if !s.cacheFailed(ctx, err) {
+				return nil, err
+			}
+			l = &lease{}
+		}
+		if filled {
+			if cachedIds, _, err := s.redIds.getJsonCtx(ctx, redisKey); err == nil {
+				return s.ListCtx(ctx, cachedIds...)
+			}
+		}
+		return s.loadListBy(ctx, index, orderBys, redisKey, l)
+	})
+	r, _ = v.([]T)
+	if shared && r != nil {
+		// every waiter gets its own slice
+		r = append([]T(nil), r...)
+	}
+	return r, err
+}
+
+// do run fn once for concurrent callers with the same key, flights of different operations must have different keys.
+// fn gets ctx without its cancellation, so that a canceled caller doesn't fail the others, which keep waiting for fn
+func (s *RedisCache[T, I]) do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error, bool) {
+	flightCtx := context.WithoutCancel(ctx)
+	ch := s.sf.DoChan(key, func() (interface{}, error) {
+		return fn(flightCtx)
+	})
+	select {
+	case r := <-ch:
+		return r.Val, r.Err, r.Shared
+	case <-ctx.Done():
+		return nil, ctx.Err(), false
 	}
+}
+
+// ListByKey redis key of the ids of records by index in order of orderBys.
+// Ordered lists are variants of the index key, deleted with it
+func (s *RedisCache[T, I]) ListByKey(index Index, orderBys OrderBys) string {
+	r := s.MakeCacheKey(index)
+	if len(orderBys) > 0 {
+		r += "/order/" + orderKey(orderBys)
+	}
+	return r
+}
+
+// registerVariant add the ListBy key of an order into the variants of the index key
+func (s *RedisCache[T, I]) registerVariant(ctx context.Context, index Index, key string) error {
+	indexKey := s.MakeCacheKey(index)
+	if key == indexKey {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	// outlive every registered list, whatever its jitter
+	return cacheError(s.red.store.SAdd(ctx, variantsKey(indexKey), s.redIds.ttl+s.redIds.jitter, key))
+}
+
+// loadListBy fetch records by index from db and fill their ids into redis
+func (s *RedisCache[T, I]) loadListBy(ctx context.Context, index Index, orderBys OrderBys, redisKey string, l *lease) ([]T, error) {
 	// search from db
-	r, err = s.db.ListBy(index, orderBys)
+	start := time.Now()
+	r, err := s.db.ListByCtx(ctx, index, orderBys)
+	s.observe("ListBy", EventDBLoad, len(r), start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -282,27 +853,65 @@ func (s *RedisCache[T, I]) ListBy(index Index, orderBys OrderBys) ([]T, error) {
 		ids[i] = v.GetID()
 	}
 	// set ids to redis
-	err = s.redIds.SetJson(redisKey, ids)
+	start = time.Now()
+	err = s.redIds.setJsonLeased(ctx, redisKey, ids, l)
+	if err == nil {
+		// register again, ClearCache may have dropped the registration while loading
+		err = s.registerVariant(ctx, index, redisKey)
+	}
+	s.observe("ListBy", EventSet, 1, start, err)
+	if err != nil && s.cacheFailed(ctx, err) {
+		return r, nil
+	}
 	return r, err
 }
 
 // ListIn list objs by index field in values
 func (s *RedisCache[T, I]) ListByUniqueInts(field string, values []int64) ([]T, error) {
+	return s.ListByUniqueIntsCtx(context.Background(), field, values)
+}
+
+// ListByUniqueIntsCtx list objs by index field in values
+func (s *RedisCache[T, I]) ListByUniqueIntsCtx(ctx context.Context, field string, values []int64) ([]T, error) {
+	if err := checkUnique[T](field); err != nil {
+		return nil, err
+	}
+	s.syncGeneration(ctx)
 	// fetch ids from redis
 	redisKeys := make([]string, len(values))
 	for i, v := range values {
 		redisKeys[i] = s.MakeCacheKey(NewIndex(field, v))
 	}
-	cachedIds, missedIndexes, err := s.redId.MGetJson(redisKeys)
+	if !s.cacheAvailable(ctx) {
+		return s.db.ListByUniqueIntsCtx(ctx, field, values)
+	}
+	start := time.Now()
+	cachedIds, missedIndexes, err := s.redId.MGetJsonCtx(ctx, redisKeys)
 	if err != nil && err != redis.Nil {
+		s.observe("ListByUniqueInts", EventError, len(values), start, err)
+		if s.cacheFailed(ctx, err) {
+			return s.db.ListByUniqueIntsCtx(ctx, field, values)
+		}
 		return nil, err
 	}
+	s.cacheSucceeded()
+	if s.observer != nil {
+		nulls := -len(missedIndexes)
+		for _, v := range cachedIds {
+			if IsNullID(v) {
+				nulls++
+			}
+		}
+		s.observeBatch("ListByUniqueInts", len(values), len(missedIndexes), nulls, start)
+	}
 	if len(missedIndexes) == 0 {
-		s.red.Expires(redisKeys...)
-		return s.List(cachedIds...)
+		s.touch(ctx, redisKeys...)
+		return s.ListCtx(ctx, cachedIds...)
 	}
 
-	rs, err := s.db.ListByUniqueInts(field, values)
+	start = time.Now()
+	rs, err := s.db.ListByUniqueIntsCtx(ctx, field, values)
+	s.observe("ListByUniqueInts", EventDBLoad, len(values), start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -324,8 +933,10 @@ func (s *RedisCache[T, I]) ListByUniqueInts(field string, values []int64) ([]T,
 	for _, v := range values {
 		indexValues[s.MakeCacheKey(NewIndex(field, v))] = indexIds[v]
 	}
-	err = s.redId.MSetJson(indexValues)
-	if err != nil {
+	start = time.Now()
+	err = s.redId.MSetJsonCtx(ctx, indexValues)
+	s.observe("ListByUniqueInts", EventSet, len(indexValues), start, err)
+	if err != nil && !s.cacheFailed(ctx, err) {
 		return nil, err
 	}
 	return rs, nil
@@ -333,21 +944,50 @@ func (s *RedisCache[T, I]) ListByUniqueInts(field string, values []int64) ([]T,
 
 // ListIn list objs by index field in values
 func (s *RedisCache[T, I]) ListByUniqueStrs(field string, values []string) ([]T, error) {
+	return s.ListByUniqueStrsCtx(context.Background(), field, values)
+}
+
+// ListByUniqueStrsCtx list objs by index field in values
+func (s *RedisCache[T, I]) ListByUniqueStrsCtx(ctx context.Context, field string, values []string) ([]T, error) {
+	if err := checkUnique[T](field); err != nil {
+		return nil, err
+	}
+	s.syncGeneration(ctx)
 	// fetch ids from redis
 	redisKeys := make([]string, len(values))
 	for i, v := range values {
 		redisKeys[i] = s.MakeCacheKey(NewIndex(field, v))
 	}
-	cachedIds, missedIndexes, err := s.redId.MGetJson(redisKeys)
+	if !s.cacheAvailable(ctx) {
+		return s.db.ListByUniqueStrsCtx(ctx, field, values)
+	}
+	start := time.Now()
+	cachedIds, missedIndexes, err := s.redId.MGetJsonCtx(ctx, redisKeys)
 	if err != nil && err != redis.Nil {
+		s.observe("ListByUniqueStrs", EventError, len(values), start, err)
+		if s.cacheFailed(ctx, err) {
+			return s.db.ListByUniqueStrsCtx(ctx, field, values)
+		}
 		return nil, err
 	}
+	s.cacheSucceeded()
+	if s.observer != nil {
+		nulls := -len(missedIndexes)
+		for _, v := range cachedIds {
+			if IsNullID(v) {
+				nulls++
+			}
+		}
+		s.observeBatch("ListByUniqueStrs", len(values), len(missedIndexes), nulls, start)
+	}
 	if len(missedIndexes) == 0 {
-		s.red.Expires(redisKeys...)
-		return s.List(cachedIds...)
+		s.touch(ctx, redisKeys...)
+		return s.ListCtx(ctx, cachedIds...)
 	}
 
-	rs, err := s.db.ListByUniqueStrs(field, values)
+	start = time.Now()
+	rs, err := s.db.ListByUniqueStrsCtx(ctx, field, values)
+	s.observe("ListByUniqueStrs", EventDBLoad, len(values), start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -361,8 +1001,10 @@ func (s *RedisCache[T, I]) ListByUniqueStrs(field string, values []string) ([]T,
 	for _, v := range values {
 		indexValues[s.MakeCacheKey(NewIndex(field, v))] = indexIds[v]
 	}
-	err = s.redId.MSetJson(indexValues)
-	if err != nil {
+	start = time.Now()
+	err = s.redId.MSetJsonCtx(ctx, indexValues)
+	s.observe("ListByUniqueStrs", EventSet, len(indexValues), start, err)
+	if err != nil && !s.cacheFailed(ctx, err) {
 		return nil, err
 	}
 	return rs, nil
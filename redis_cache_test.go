@@ -0,0 +1,116 @@
+package scache_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/scache"
+	"github.com/daqiancode/scache/scachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelayedDelete(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	db := scachetest.NewMemoryDB[member, int]("Id")
+	assert.Nil(t, db.Create(&member{Id: 1, Group: 1}))
+	ca := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	var mu sync.Mutex
+	var failed []error
+	ca.SetDelayedDelete(50*time.Millisecond, func(keys []string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.NotEmpty(t, keys)
+		failed = append(failed, err)
+	})
+
+	_, err := ca.Update(1, map[string]interface{}{"Group": 2})
+	assert.Nil(t, err)
+	// a concurrent reader fills the cache with the old row after the first deletion
+	_, err = ca.Get(1)
+	assert.Nil(t, err)
+	_, err = db.Update(1, map[string]interface{}{"Group": 3})
+	assert.Nil(t, err)
+	eventually(t, func() bool {
+		r, err := ca.Get(1)
+		return err == nil && r.Group == 3
+	}, "second delete not run")
+
+	// the error of the second deletion reaches the hook
+	_, err = ca.Update(1, map[string]interface{}{"Group": 4})
+	assert.Nil(t, err)
+	mr.SetError("boom")
+	eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(failed) == 1
+	}, "hook not called")
+	assert.ErrorIs(t, failed[0], scache.ErrCacheUnavailable)
+	mr.SetError("")
+
+	// Close drops pending deletions
+	_, err = ca.Update(1, map[string]interface{}{"Group": 5})
+	assert.Nil(t, err)
+	mr.SetError("boom")
+	assert.Nil(t, ca.Close())
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, failed, 1)
+}
+
+func TestListByOrders(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	db := scachetest.NewMemoryDB[member, int]("Id")
+	assert.Nil(t, db.Create(&member{Id: 1, Group: 1}))
+	assert.Nil(t, db.Create(&member{Id: 2, Group: 1}))
+	ca := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	index := scache.NewIndex("Group", 1)
+	asc := scache.NewOrderBys("Id", true)
+	desc := scache.NewOrderBys("Id", false)
+
+	rs, err := ca.ListBy(index, asc)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 2}, ids(rs))
+	rs, err = ca.ListBy(index, desc)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{2, 1}, ids(rs))
+	_, err = ca.ListBy(index, nil)
+	assert.Nil(t, err)
+	variants := []string{ca.ListByKey(index, nil), ca.ListByKey(index, asc), ca.ListByKey(index, desc)}
+	assert.Len(t, scache.UniqueStrings(variants), 3)
+	for _, k := range variants {
+		assert.True(t, mr.Exists(k), k)
+	}
+
+	assert.Nil(t, ca.ClearCache(member{Id: 1, Group: 1}))
+	for _, k := range variants {
+		assert.False(t, mr.Exists(k), k)
+	}
+}
+
+func TestListByOrderExpiry(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	db := scachetest.NewMemoryDB[member, int]("Id")
+	assert.Nil(t, db.Create(&member{Id: 1, Group: 1}))
+	assert.Nil(t, db.Create(&member{Id: 2, Group: 1}))
+	ca := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	ca.SetTTLJitter(10 * time.Second)
+	index := scache.NewIndex("Group", 1)
+	asc := scache.NewOrderBys("Id", true)
+
+	_, err := ca.ListBy(index, asc)
+	assert.Nil(t, err)
+	// a hit keeps the list and its variants alive together, whatever the jitter
+	mr.FastForward(50 * time.Second)
+	_, err = ca.ListBy(index, asc)
+	assert.Nil(t, err)
+	assert.Equal(t, 70*time.Second, mr.TTL(ca.MakeCacheKey(index)+"/variants"))
+	assert.Less(t, mr.TTL(ca.ListByKey(index, asc)), 70*time.Second)
+	mr.FastForward(20 * time.Second)
+	_, err = ca.Update(2, map[string]interface{}{"Group": 2})
+	assert.Nil(t, err)
+	rs, err := ca.ListBy(index, asc)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1}, ids(rs))
+}
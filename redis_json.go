@@ -2,6 +2,7 @@ package scache
 
 import (
 	"context"
+	"math/rand"
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
@@ -28,194 +29,416 @@ func (s *JsonSerializer) Unmarshal(data string, objRef interface{}) error {
 }
 
 type RedisJson[T any] struct {
-	*redis.Client
+	store      Store
 	serializer Serializer
 	ttl        time.Duration
+	// local optional in-process cache checked before redis
+	local LocalCache
+	// softTTL entries older than it are stale, 0 means disabled
+	softTTL time.Duration
+	// jitter max random duration added to every ttl written
+	jitter time.Duration
+	// nullTTL ttl of null entries, 0 means ttl
+	nullTTL time.Duration
+	logger  Logger
+}
+
+// hit how a cached value was read
+type hit struct {
+	// local found in local cache
+	local bool
+	// stale older than soft ttl, should be refreshed
+	stale bool
+	// null cached as not found
+	null bool
 }
 
 func NewRedisJson[T any](client *redis.Client, ttl time.Duration) *RedisJson[T] {
+	return NewRedisJsonWithStore[T](NewRedisStore(client), ttl)
+}
+
+// NewRedisJsonWithStore like NewRedisJson, entries are kept in store, eg. NewMemoryStore()
+func NewRedisJsonWithStore[T any](store Store, ttl time.Duration) *RedisJson[T] {
 	return &RedisJson[T]{
-		Client:     client,
+		store:      store,
 		serializer: &JsonSerializer{},
 		ttl:        ttl,
 	}
 }
 
+func (s *RedisJson[T]) GetStore() Store {
+	return s.store
+}
+
+// Client redis client of the store, nil if the store is not backed by redis
+func (s *RedisJson[T]) Client() *redis.Client {
+	return redisClient(s.store)
+}
+
+// SetTTLJitter add a random duration in [0,jitter) to every ttl written, so that entries written together don't expire together
+func (s *RedisJson[T]) SetTTLJitter(jitter time.Duration) {
+	s.jitter = jitter
+}
+func (s *RedisJson[T]) GetTTLJitter() time.Duration {
+	return s.jitter
+}
+
+// SetNullTTL ttl of null entries of records not found, usually shorter than ttl so that new records show up soon. 0 means ttl
+func (s *RedisJson[T]) SetNullTTL(ttl time.Duration) {
+	s.nullTTL = ttl
+}
+func (s *RedisJson[T]) GetNullTTL() time.Duration {
+	return s.nullTTL
+}
+
+// expiration ttl of an entry written now
+func (s *RedisJson[T]) expiration() time.Duration {
+	return s.withJitter(s.ttl)
+}
+
+// nullExpiration ttl of a null entry written now
+func (s *RedisJson[T]) nullExpiration() time.Duration {
+	if s.nullTTL > 0 {
+		return s.withJitter(s.nullTTL)
+	}
+	return s.expiration()
+}
+
+func (s *RedisJson[T]) withJitter(ttl time.Duration) time.Duration {
+	if s.jitter <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Int63n(int64(s.jitter)))
+}
+
+// SetSerializer change the encoding of cached objects, entries written with the previous serializer can't be decoded
+func (s *RedisJson[T]) SetSerializer(serializer Serializer) {
+	s.serializer = serializer
+}
+func (s *RedisJson[T]) GetSerializer() Serializer {
+	return s.serializer
+}
+
+// SetLocalCache put an in-process cache in front of redis, nil to disable
+func (s *RedisJson[T]) SetLocalCache(local LocalCache) {
+	s.local = local
+}
+func (s *RedisJson[T]) GetLocalCache() LocalCache {
+	return s.local
+}
+
+// localNull local entry of a null redis entry, which is told apart from zero values
+type localNull struct{}
+
+func (s *RedisJson[T]) getLocal(key string) (T, hit, bool) {
+	var r T
+	if s.local == nil {
+		return r, hit{}, false
+	}
+	v, ok := s.local.Get(key)
+	if !ok {
+		return r, hit{}, false
+	}
+	if _, ok := v.(localNull); ok {
+		return r, hit{local: true, null: true}, true
+	}
+	// the same key may be cached with another type, eg. GetBy & ListBy on one index
+	r, ok = v.(T)
+	return r, hit{local: true}, ok
+}
+
+func (s *RedisJson[T]) setLocal(key string, obj interface{}) {
+	if s.local != nil {
+		s.local.Set(key, obj)
+	}
+}
+
 func (s *RedisJson[T]) GetJson(key string) (T, error) {
+	return s.GetJsonCtx(context.Background(), key)
+}
+
+func (s *RedisJson[T]) GetJsonCtx(ctx context.Context, key string) (T, error) {
+	r, _, err := s.getJsonCtx(ctx, key)
+	return r, err
+}
+
+func (s *RedisJson[T]) getJsonCtx(ctx context.Context, key string) (T, hit, error) {
+	if r, h, ok := s.getLocal(key); ok {
+		return r, h, nil
+	}
 	var r T
-	ctx, cancel := context.WithTimeout(context.Background(), RdisOpTimeout)
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
 	defer cancel()
-	y, err := s.Get(ctx, key).Result()
+	y, err := s.store.Get(ctx, key)
 	if err != nil {
-		return r, err
+		return r, hit{}, cacheError(err)
 	}
-	err = s.serializer.Unmarshal(y, &r)
-	return r, err
+	h, err := s.decode(y, &r)
+	if err != nil {
+		return r, hit{}, err
+	}
+	// stale entries stay in redis only, until refreshed
+	if h.stale {
+		return r, h, nil
+	}
+	if h.null {
+		s.setLocal(key, localNull{})
+	} else {
+		s.setLocal(key, r)
+	}
+	return r, h, nil
 }
 
 func (s *RedisJson[T]) SetJson(key string, obj T) error {
+	return s.SetJsonCtx(context.Background(), key, obj)
+}
 
+func (s *RedisJson[T]) SetJsonCtx(ctx context.Context, key string, obj T) error {
 	y, err := s.serializer.Marshal(obj)
 	if err != nil {
 		return err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), RdisOpTimeout)
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
 	defer cancel()
-	return s.SetEx(ctx, key, y, s.ttl).Err()
+	if err = s.store.Set(ctx, key, s.encode(y), s.expiration()); err != nil {
+		return cacheError(err)
+	}
+	s.setLocal(key, obj)
+	return nil
 }
 
 func (s *RedisJson[T]) MSetJson(objMap map[string]interface{}) error {
+	return s.MSetJsonCtx(context.Background(), objMap)
+}
+
+func (s *RedisJson[T]) MSetJsonCtx(ctx context.Context, objMap map[string]interface{}) error {
 	if len(objMap) == 0 {
 		return nil
 	}
-	objJsonMap := make(map[string]string, len(objMap))
-	var err error
-	keys := make([]string, len(objMap))
-	i := 0
+	entries := make([]Entry, 0, len(objMap))
 	for k, v := range objMap {
-		objJsonMap[k], err = s.serializer.Marshal(v)
+		y, err := s.serializer.Marshal(v)
 		if err != nil {
 			return err
 		}
-		keys[i] = k
-		i++
+		entries = append(entries, Entry{Key: k, Value: s.encode(y), TTL: s.expiration()})
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), RdisOpTimeout)
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
 	defer cancel()
-	err = s.MSet(ctx, objJsonMap).Err()
-	if err != nil {
-		return err
+	if err := s.store.MSet(ctx, entries...); err != nil {
+		return cacheError(err)
+	}
+	for k, v := range objMap {
+		s.setLocal(k, v)
 	}
-	return s.Expires(keys...)
+	return nil
 }
 
 func (s *RedisJson[T]) Expires(keys ...string) error {
+	return s.ExpiresCtx(context.Background(), keys...)
+}
+
+func (s *RedisJson[T]) ExpiresCtx(ctx context.Context, keys ...string) error {
 	if len(keys) == 0 {
 		return nil
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), RdisOpTimeout)
-	defer cancel()
-	p := s.Pipeline()
-	var err error
+	ttls := make(map[string]time.Duration, len(keys))
 	for _, v := range keys {
-		err = p.Expire(ctx, v, s.ttl).Err()
-		if err != nil {
-			return err
-		}
+		ttls[v] = s.expiration()
 	}
-	_, err = p.Exec(ctx)
-	return err
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	return cacheError(s.store.Expire(ctx, ttls))
+}
 
+func (s *RedisJson[T]) Del(keys ...string) error {
+	return s.DelCtx(context.Background(), keys...)
+}
+
+// DelCtx delete keys from redis and the local cache
+func (s *RedisJson[T]) DelCtx(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if s.local != nil {
+		s.local.Del(keys...)
+	}
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	return cacheError(s.store.Del(ctx, keys...))
 }
 
 func (s *RedisJson[T]) SetNull(key string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), RdisOpTimeout)
+	return s.SetNullCtx(context.Background(), key)
+}
+
+func (s *RedisJson[T]) SetNullCtx(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
 	defer cancel()
-	return s.SetEx(ctx, key, "null", s.ttl).Err()
+	if err := s.store.Set(ctx, key, s.encode(nullValue), s.nullExpiration()); err != nil {
+		return cacheError(err)
+	}
+	s.setLocal(key, localNull{})
+	return nil
 }
 
 func (s *RedisJson[T]) MSetNull(keys []string) error {
+	return s.MSetNullCtx(context.Background(), keys)
+}
+
+func (s *RedisJson[T]) MSetNullCtx(ctx context.Context, keys []string) error {
 	if len(keys) == 0 {
 		return nil
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), RdisOpTimeout)
+	entries := make([]Entry, len(keys))
+	for i, v := range keys {
+		entries[i] = Entry{Key: v, Value: s.encode(nullValue), TTL: s.nullExpiration()}
+	}
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
 	defer cancel()
-	p := s.Pipeline()
-	var err error
+	if err := s.store.MSet(ctx, entries...); err != nil {
+		return cacheError(err)
+	}
 	for _, v := range keys {
-		err = p.SetEx(ctx, v, "null", s.ttl).Err()
-		if err != nil {
-			return err
-		}
+		s.setLocal(v, localNull{})
 	}
-	_, err = p.Exec(ctx)
-	return err
+	return nil
 }
 
 func (s *RedisJson[T]) MGetJson(keys []string) ([]T, []int, error) {
+	return s.MGetJsonCtx(context.Background(), keys)
+}
+
+func (s *RedisJson[T]) MGetJsonCtx(ctx context.Context, keys []string) ([]T, []int, error) {
+	r, missedIndexes, _, err := s.mGetJsonCtx(ctx, keys)
+	return r, missedIndexes, err
+}
+
+// mGetJsonCtx return (objs, indexes of missed keys, indexes of stale keys, error)
+func (s *RedisJson[T]) mGetJsonCtx(ctx context.Context, keys []string) ([]T, []int, []int, error) {
 	if len(keys) == 0 {
-		return nil, nil, nil
+		return nil, nil, nil, nil
+	}
+	r := make([]T, len(keys))
+	// keys found in local cache are not fetched from redis, positions[i] is the index of redisKeys[i] in keys
+	redisKeys := make([]string, 0, len(keys))
+	positions := make([]int, 0, len(keys))
+	for i, key := range keys {
+		if t, _, ok := s.getLocal(key); ok {
+			r[i] = t
+			continue
+		}
+		redisKeys = append(redisKeys, key)
+		positions = append(positions, i)
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), RdisOpTimeout)
+	if len(redisKeys) == 0 {
+		return r, nil, nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
 	defer cancel()
-	vs, err := s.MGet(ctx, keys...).Result()
+	vs, err := s.store.MGet(ctx, redisKeys...)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, cacheError(err)
 	}
-	var missedIndexes []int
-	r := make([]T, len(keys))
+	var missedIndexes, staleIndexes []int
+	// null entries keep their own ttl
+	hitKeys := make(map[string]time.Duration)
 	for i, v := range vs {
 		var t T
 		if v == nil {
-			missedIndexes = append(missedIndexes, i)
-			r[i] = t
+			missedIndexes = append(missedIndexes, positions[i])
 			continue
 		}
-
-		err = s.serializer.Unmarshal(v.(string), &t)
+		h, err := s.decode(v.(string), &t)
 		if err != nil {
-			return nil, missedIndexes, err
+			return nil, missedIndexes, nil, err
 		}
-		r[i] = t
-	}
-	for _, key := range keys {
-		err = s.Expire(ctx, key, s.ttl).Err()
-		if err != nil {
-			return r, missedIndexes, err
+		r[positions[i]] = t
+		if h.stale {
+			staleIndexes = append(staleIndexes, positions[i])
+		} else if h.null {
+			s.setLocal(redisKeys[i], localNull{})
+		} else {
+			s.setLocal(redisKeys[i], t)
 		}
+		if !h.null {
+			hitKeys[redisKeys[i]] = s.expiration()
+		}
+	}
+	if err = s.store.Expire(ctx, hitKeys); err != nil {
+		return r, missedIndexes, staleIndexes, cacheError(err)
 	}
-	return r, missedIndexes, nil
+	return r, missedIndexes, staleIndexes, nil
 
 }
 
 type RedisHashJson[T Table[I], I IDType] struct {
 	*RedisJson[T]
-	*redis.Client
 	serializer Serializer
-	ctx        context.Context
 	ttl        time.Duration
 }
 
 func NewRedisHashJson[T Table[I], I IDType](client *redis.Client, ttl time.Duration) *RedisHashJson[T, I] {
+	return NewRedisHashJsonWithStore[T, I](NewRedisStore(client), ttl)
+}
+
+// NewRedisHashJsonWithStore like NewRedisHashJson, hashes are kept in store
+func NewRedisHashJsonWithStore[T Table[I], I IDType](store Store, ttl time.Duration) *RedisHashJson[T, I] {
 	return &RedisHashJson[T, I]{
-		RedisJson:  NewRedisJson[T](client, ttl),
-		Client:     client,
+		RedisJson:  NewRedisJsonWithStore[T](store, ttl),
 		serializer: &JsonSerializer{},
-		ctx:        context.Background(),
 		ttl:        ttl,
 	}
 }
 
+// SetSerializer change the encoding of cached objects, entries written with the previous serializer can't be decoded
+func (s *RedisHashJson[T, I]) SetSerializer(serializer Serializer) {
+	s.serializer = serializer
+	s.RedisJson.SetSerializer(serializer)
+}
+func (s *RedisHashJson[T, I]) GetSerializer() Serializer {
+	return s.serializer
+}
+
 func (s *RedisHashJson[T, I]) HGetJson(key string, id I) (T, error) {
+	return s.HGetJsonCtx(context.Background(), key, id)
+}
+
+func (s *RedisHashJson[T, I]) HGetJsonCtx(ctx context.Context, key string, id I) (T, error) {
 	idStr := Stringify(id, "")
-	ctx, cancel := context.WithTimeout(context.Background(), RdisOpTimeout)
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
 	defer cancel()
 	var r T
-	raw, err := s.HGet(ctx, key, idStr).Result()
+	raw, err := s.store.HGet(ctx, key, idStr)
 	if err != nil {
 
-		return r, err
+		return r, cacheError(err)
 	}
 	err = s.serializer.Unmarshal(raw, &r)
-	return r, err
+	return r, decodeError(err)
 }
 
 func (s *RedisHashJson[T, I]) HGetAllJson(key string) ([]T, error) {
+	return s.HGetAllJsonCtx(context.Background(), key)
+}
+
+func (s *RedisHashJson[T, I]) HGetAllJsonCtx(ctx context.Context, key string) ([]T, error) {
 	var r []T
-	ctx, cancel := context.WithTimeout(context.Background(), RdisOpTimeout)
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
 	defer cancel()
-	raw, err := s.HGetAll(ctx, key).Result()
+	raw, err := s.store.HGetAll(ctx, key)
 	if err != nil {
 		if err == redis.Nil {
 			return r, nil
 		}
-		return r, err
+		return r, cacheError(err)
 	}
-	for _, v := range raw {
+	for k, v := range raw {
 		var t T
-		err = s.serializer.Unmarshal(v, &t)
-		if err != nil {
-			return r, nil
+		if err = s.serializer.Unmarshal(v, &t); err != nil {
+			// skip bad entries
+			s.logDecodeError(key, k, err)
+			continue
 		}
 		r = append(r, t)
 	}
@@ -223,6 +446,10 @@ func (s *RedisHashJson[T, I]) HGetAllJson(key string) ([]T, error) {
 }
 
 func (s *RedisHashJson[T, I]) HMGetJson(key string, ids ...I) ([]T, error) {
+	return s.HMGetJsonCtx(context.Background(), key, ids...)
+}
+
+func (s *RedisHashJson[T, I]) HMGetJsonCtx(ctx context.Context, key string, ids ...I) ([]T, error) {
 	if len(ids) == 0 {
 		return nil, nil
 	}
@@ -230,24 +457,25 @@ func (s *RedisHashJson[T, I]) HMGetJson(key string, ids ...I) ([]T, error) {
 	for i, v := range ids {
 		idStrs[i] = Stringify(v, "")
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), RdisOpTimeout)
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
 	defer cancel()
 	var r []T
-	raw, err := s.HMGet(ctx, key, idStrs...).Result()
+	raw, err := s.store.HMGet(ctx, key, idStrs...)
 	if err != nil {
 		if err == redis.Nil {
 			return r, nil
 		}
-		return r, err
+		return r, cacheError(err)
 	}
-	for _, v := range raw {
+	for i, v := range raw {
 		if v == nil {
 			continue
 		}
 		var t T
-		err = s.serializer.Unmarshal(v.(string), &t)
-		if err != nil {
-			return r, nil
+		if err = s.serializer.Unmarshal(v.(string), &t); err != nil {
+			// skip bad entries
+			s.logDecodeError(key, idStrs[i], err)
+			continue
 		}
 		r = append(r, t)
 	}
@@ -255,24 +483,31 @@ func (s *RedisHashJson[T, I]) HMGetJson(key string, ids ...I) ([]T, error) {
 }
 
 func (s *RedisHashJson[T, I]) HSetJson(key string, objs ...T) error {
+	return s.HSetJsonCtx(context.Background(), key, objs...)
+}
+
+func (s *RedisHashJson[T, I]) HSetJsonCtx(ctx context.Context, key string, objs ...T) error {
 	if len(objs) == 0 {
 		return nil
 	}
-	var err error
-	args := make([]string, len(objs)*2)
-	for k, v := range objs {
-		args[2*k] = Stringify(v.GetID(), "")
-		args[2*k+1], err = s.serializer.Marshal(v)
+	values := make(map[string]string, len(objs))
+	for _, v := range objs {
+		y, err := s.serializer.Marshal(v)
 		if err != nil {
 			return err
 		}
+		values[Stringify(v.GetID(), "")] = y
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), RdisOpTimeout)
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
 	defer cancel()
-	return s.HSet(ctx, key, args).Err()
+	return cacheError(s.store.HSet(ctx, key, values))
 }
 
 func (s *RedisHashJson[T, I]) HDelJson(key string, ids ...I) error {
+	return s.HDelJsonCtx(context.Background(), key, ids...)
+}
+
+func (s *RedisHashJson[T, I]) HDelJsonCtx(ctx context.Context, key string, ids ...I) error {
 	if len(ids) == 0 {
 		return nil
 	}
@@ -280,7 +515,7 @@ func (s *RedisHashJson[T, I]) HDelJson(key string, ids ...I) error {
 	for i, v := range ids {
 		idStrs[i] = Stringify(v, "")
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), RdisOpTimeout)
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
 	defer cancel()
-	return s.HDel(ctx, key, idStrs...).Err()
+	return cacheError(s.store.HDel(ctx, key, idStrs...))
 }
@@ -0,0 +1,77 @@
+package scache_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/scache"
+	"github.com/daqiancode/scache/scachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTLJitter(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	r := scache.NewRedisJson[doc](red, time.Minute)
+	r.SetTTLJitter(10 * time.Second)
+	r.SetNullTTL(5 * time.Second)
+	objs := make(map[string]interface{})
+	var keys, nulls []string
+	for i := 0; i < 20; i++ {
+		assert.Nil(t, r.SetJson(fmt.Sprint("a", i), doc{Id: "1"}))
+		objs[fmt.Sprint("b", i)] = doc{Id: "1"}
+		assert.Nil(t, r.SetNull(fmt.Sprint("c", i)))
+		nulls = append(nulls, fmt.Sprint("d", i))
+		keys = append(keys, fmt.Sprint("a", i), fmt.Sprint("b", i))
+	}
+	assert.Nil(t, r.MSetJson(objs))
+	assert.Nil(t, r.MSetNull(nulls))
+
+	ttls := make(map[time.Duration]bool)
+	for _, k := range keys {
+		ttl := mr.TTL(k)
+		assert.GreaterOrEqual(t, ttl, time.Minute, k)
+		assert.Less(t, ttl, time.Minute+10*time.Second, k)
+		ttls[ttl] = true
+	}
+	assert.Greater(t, len(ttls), 1)
+	for i := 0; i < 20; i++ {
+		for _, k := range []string{fmt.Sprint("c", i), fmt.Sprint("d", i)} {
+			ttl := mr.TTL(k)
+			assert.GreaterOrEqual(t, ttl, 5*time.Second, k)
+			assert.Less(t, ttl, 15*time.Second, k)
+		}
+	}
+}
+
+func TestNullTTL(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	db := scachetest.NewMemoryDB[member, int]("Id")
+	assert.Nil(t, db.Create(&member{Id: 1, Group: 1}))
+	ca := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	ca.SetNullTTL(5 * time.Second)
+
+	_, err := ca.Get(2)
+	assert.Equal(t, scache.ErrRecordNotFound, err)
+	_, err = ca.GetBy(scache.NewIndex("Group", 2))
+	assert.Equal(t, scache.ErrRecordNotFound, err)
+	rs, err := ca.List(1, 3)
+	assert.Nil(t, err)
+	assert.Len(t, rs, 2)
+
+	assert.Equal(t, time.Minute, mr.TTL(ca.MakeCacheKey(scache.NewIndex("Id", 1))))
+	for _, index := range []scache.Index{scache.NewIndex("Id", 2), scache.NewIndex("Id", 3), scache.NewIndex("Group", 2)} {
+		assert.Equal(t, 5*time.Second, mr.TTL(ca.MakeCacheKey(index)), index)
+	}
+	// null entries keep their ttl when read
+	_, err = ca.Get(2)
+	assert.Equal(t, scache.ErrRecordNotFound, err)
+	assert.Equal(t, 5*time.Second, mr.TTL(ca.MakeCacheKey(scache.NewIndex("Id", 2))))
+}
+
+func TestRedisJsonClient(t *testing.T) {
+	red, _ := scachetest.NewRedis(t)
+	assert.Equal(t, red, scache.NewRedisJson[doc](red, time.Minute).Client())
+	assert.Equal(t, red, scache.NewRedisHashJson[member, int](red, time.Minute).Client())
+	assert.Nil(t, scache.NewRedisJsonWithStore[doc](scache.NewMemoryStore(), time.Minute).Client())
+}
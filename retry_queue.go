@@ -0,0 +1,274 @@
+package scache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RetryOptions retrying failed invalidations
+type RetryOptions struct {
+	// MinBackoff delay of the first retry, doubled on every failure. <=0 means 100ms
+	MinBackoff time.Duration
+	// MaxBackoff max delay between retries, <=0 means 30s
+	MaxBackoff time.Duration
+	// ListKey redis list keeping failed keys across restarts, empty means in-process only.
+	// The list is only durable on a separate Client: on the cache redis, pushes fail while it is down, and the keys are retried in process only
+	ListKey string
+	// Client redis client of ListKey, nil means the client of the cache, required by stores not backed by redis
+	Client *redis.Client
+}
+
+// RetryQueue retry deleting keys with exponential backoff until it succeeds.
+// Keys are coalesced, and optionally pushed to a redis list, which is reloaded by Recover after restarts
+type RetryQueue struct {
+	del    func(ctx context.Context, keys []string) error
+	opts   RetryOptions
+	logger Logger
+
+	mu      sync.Mutex
+	pending map[string]struct{}
+	// inflight keys being deleted by a flush
+	inflight int
+	// entries items of the redis list covered by pending
+	entries []string
+	attempt int
+	timer   *time.Timer
+	closed  bool
+	// flushMu one flush at a time
+	flushMu sync.Mutex
+}
+
+func NewRetryQueue(del func(ctx context.Context, keys []string) error, opts RetryOptions) *RetryQueue {
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 100 * time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 30 * time.Second
+	}
+	return &RetryQueue{del: del, opts: opts, pending: make(map[string]struct{})}
+}
+
+// SetLogger report failed retries
+func (s *RetryQueue) SetLogger(logger Logger) {
+	s.logger = logger
+}
+
+// Backlog number of keys waiting for retry, including the ones being retried
+func (s *RetryQueue) Backlog() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending) + s.inflight
+}
+
+// Add queue keys for retry
+func (s *RetryQueue) Add(ctx context.Context, keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	entry := s.push(ctx, keys)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range keys {
+		s.pending[k] = struct{}{}
+	}
+	if entry != "" {
+		s.entries = append(s.entries, entry)
+	}
+	s.schedule()
+}
+
+// push keys to the redis list, return the list item, empty if not pushed
+func (s *RetryQueue) push(ctx context.Context, keys []string) string {
+	if s.opts.ListKey == "" {
+		return ""
+	}
+	entry, err := json.MarshalToString(keys)
+	if err != nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	if err := s.opts.Client.RPush(ctx, s.opts.ListKey, entry).Err(); err != nil {
+		s.warn("scache: push to retry list failed, keys are retried in process only", err)
+		return ""
+	}
+	return entry
+}
+
+// Recover load keys left in the redis list, eg. by a previous process
+func (s *RetryQueue) Recover(ctx context.Context) error {
+	if s.opts.ListKey == "" {
+		return nil
+	}
+	redCtx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	entries, err := s.opts.Client.LRange(redCtx, s.opts.ListKey, 0, -1).Result()
+	if err != nil {
+		return cacheError(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range entries {
+		var keys []string
+		if err := json.UnmarshalFromString(entry, &keys); err != nil {
+			s.warn("scache: bad retry list item", err)
+		}
+		for _, k := range keys {
+			s.pending[k] = struct{}{}
+		}
+		// bad items are removed too
+		s.entries = append(s.entries, entry)
+	}
+	s.schedule()
+	return nil
+}
+
+// Flush delete all pending keys now, they stay pending on failure
+func (s *RetryQueue) Flush(ctx context.Context) error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	return s.flush(ctx, s.del)
+}
+
+// tryFlush flush with del unless another flush is running, return whether it flushed
+func (s *RetryQueue) tryFlush(ctx context.Context, del func(ctx context.Context, keys []string) error) (bool, error) {
+	if !s.flushMu.TryLock() {
+		return false, nil
+	}
+	defer s.flushMu.Unlock()
+	return true, s.flush(ctx, del)
+}
+
+func (s *RetryQueue) flush(ctx context.Context, del func(ctx context.Context, keys []string) error) error {
+	s.mu.Lock()
+	keys := make([]string, 0, len(s.pending))
+	for k := range s.pending {
+		keys = append(keys, k)
+	}
+	entries := s.entries
+	s.pending = make(map[string]struct{})
+	s.entries = nil
+	s.inflight = len(keys)
+	s.mu.Unlock()
+	if len(keys) == 0 && len(entries) == 0 {
+		return nil
+	}
+	var err error
+	if len(keys) > 0 {
+		err = del(ctx, keys)
+	}
+	s.mu.Lock()
+	s.inflight = 0
+	if err != nil {
+		for _, k := range keys {
+			s.pending[k] = struct{}{}
+		}
+		s.entries = append(entries, s.entries...)
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Unlock()
+	s.remove(ctx, entries)
+	return nil
+}
+
+// remove done items from the redis list, other processes may share it
+func (s *RetryQueue) remove(ctx context.Context, entries []string) {
+	if len(entries) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, RdisOpTimeout)
+	defer cancel()
+	p := s.opts.Client.Pipeline()
+	for _, entry := range entries {
+		p.LRem(ctx, s.opts.ListKey, 1, entry)
+	}
+	if _, err := p.Exec(ctx); err != nil {
+		// retried keys are deleted again after restart, which is harmless
+		s.warn("scache: remove from retry list failed", err)
+	}
+}
+
+// schedule the next retry with backoff, s.mu must be held
+func (s *RetryQueue) schedule() {
+	if s.closed || s.timer != nil || len(s.pending)+len(s.entries) == 0 {
+		return
+	}
+	delay := s.opts.MinBackoff << s.attempt
+	if delay <= 0 || delay > s.opts.MaxBackoff {
+		delay = s.opts.MaxBackoff
+	} else {
+		s.attempt++
+	}
+	s.timer = time.AfterFunc(delay, s.retry)
+}
+
+func (s *RetryQueue) retry() {
+	err := s.Flush(context.Background())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timer = nil
+	if err != nil {
+		s.warn("scache: retry invalidation failed", err)
+	} else {
+		s.attempt = 0
+	}
+	s.schedule()
+}
+
+// Close stop retrying, keys in the redis list are kept for Recover
+func (s *RetryQueue) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+}
+
+func (s *RetryQueue) warn(msg string, err error) {
+	if s.logger != nil {
+		s.logger.Warn(msg, "list", s.opts.ListKey, "error", err)
+	}
+}
+
+// EnableRetryQueue retry invalidations which failed after writes in background, and reload the backlog left in opts.ListKey
+func (s *RedisCache[T, I]) EnableRetryQueue(opts RetryOptions) error {
+	if opts.Client == nil {
+		opts.Client = s.client()
+	}
+	if opts.ListKey != "" && opts.Client == nil {
+		return fmt.Errorf("%w: ListKey needs a redis Client", ErrNotSupported)
+	}
+	if s.retry != nil {
+		s.retry.Close()
+	}
+	s.retry = NewRetryQueue(s.retryDel, opts)
+	s.retry.SetLogger(s.logger)
+	return s.retry.Recover(context.Background())
+}
+
+// retryDel delete keys unless the circuit breaker is open
+func (s *RedisCache[T, I]) retryDel(ctx context.Context, keys []string) error {
+	if !s.cacheAvailable(ctx) {
+		return ErrCacheUnavailable
+	}
+	err := s.delKeys(ctx, keys)
+	if err != nil {
+		s.cacheFailed(ctx, err)
+	}
+	return err
+}
+
+// PendingInvalidations number of keys waiting for retry, to alert on
+func (s *RedisCache[T, I]) PendingInvalidations() int {
+	if s.retry == nil {
+		return 0
+	}
+	return s.retry.Backlog()
+}
@@ -0,0 +1,81 @@
+package scache_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/scache"
+	"github.com/daqiancode/scache/scachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryQueue(t *testing.T) {
+	var mu sync.Mutex
+	var deleted []string
+	fails := 2
+	q := scache.NewRetryQueue(func(ctx context.Context, keys []string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if fails > 0 {
+			fails--
+			return errors.New("redis down")
+		}
+		deleted = append(deleted, keys...)
+		return nil
+	}, scache.RetryOptions{MinBackoff: 20 * time.Millisecond, MaxBackoff: 50 * time.Millisecond})
+	defer q.Close()
+
+	q.Add(context.Background(), "a", "b")
+	q.Add(context.Background(), "b", "c")
+	assert.Equal(t, 3, q.Backlog())
+	assert.Eventually(t, func() bool { return q.Backlog() == 0 }, time.Second, time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	sort.Strings(deleted)
+	assert.Equal(t, []string{"a", "b", "c"}, deleted)
+}
+
+func TestRetryQueueList(t *testing.T) {
+	client, mr := scachetest.NewRedis(t)
+	ctx := context.Background()
+	var mu sync.Mutex
+	down := true
+	var deleted []string
+	del := func(ctx context.Context, keys []string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			return errors.New("redis down")
+		}
+		deleted = append(deleted, keys...)
+		return nil
+	}
+	opts := scache.RetryOptions{MinBackoff: time.Hour, ListKey: "retry", Client: client}
+
+	// failed keys are pushed to the list
+	q := scache.NewRetryQueue(del, opts)
+	q.Add(ctx, "a", "b")
+	assert.NotNil(t, q.Flush(ctx))
+	items, err := mr.List("retry")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`["a","b"]`}, items)
+	q.Close()
+
+	// restart: a new queue reloads them, and removes them once deleted
+	q = scache.NewRetryQueue(del, opts)
+	defer q.Close()
+	assert.Nil(t, q.Recover(ctx))
+	assert.Equal(t, 2, q.Backlog())
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	assert.Nil(t, q.Flush(ctx))
+	assert.Equal(t, 0, q.Backlog())
+	sort.Strings(deleted)
+	assert.Equal(t, []string{"a", "b"}, deleted)
+	assert.False(t, mr.Exists("retry"))
+}
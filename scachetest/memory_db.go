@@ -0,0 +1,414 @@
+package scachetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/daqiancode/scache"
+)
+
+// ErrDuplicateID Create with an existing id
+var ErrDuplicateID = errors.New("scachetest: duplicate id")
+
+// MemoryDB in-memory scache.DBCRUD & scache.FullDBCache for tests.
+// Records are matched by index like cache keys, ie. by the string form of values, and listed in order of id by default
+type MemoryDB[T scache.Table[I], I scache.IDType] struct {
+	idField string
+	mu      sync.RWMutex
+	rows    map[I]T
+	// seq last generated id
+	seq int64
+	// reads number of read calls
+	reads int64
+}
+
+// NewMemoryDB empty idField means the id field declared by scache tags of T
+func NewMemoryDB[T scache.Table[I], I scache.IDType](idField string) *MemoryDB[T, I] {
+	if idField == "" {
+		idField = scache.MetaOf[T]().IdField
+	}
+	return &MemoryDB[T, I]{idField: idField, rows: make(map[I]T)}
+}
+
+// Reads number of read calls, to tell cache hits from db loads
+func (s *MemoryDB[T, I]) Reads() int64 {
+	return atomic.LoadInt64(&s.reads)
+}
+
+func (s *MemoryDB[T, I]) read() {
+	atomic.AddInt64(&s.reads, 1)
+}
+
+func (s *MemoryDB[T, I]) Close() error {
+	return nil
+}
+
+func (s *MemoryDB[T, I]) Create(obj *T) error {
+	return s.CreateCtx(context.Background(), obj)
+}
+
+// CreateCtx a null id is generated: max int id + 1, or a numeric string
+func (s *MemoryDB[T, I]) CreateCtx(ctx context.Context, obj *T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := (*obj).GetID()
+	if scache.IsNullID(id) {
+		var err error
+		if id, err = s.nextID(obj); err != nil {
+			return err
+		}
+	} else if _, ok := s.rows[id]; ok {
+		return scache.NewDBError("Create", "memory", ErrDuplicateID)
+	}
+	s.rows[id] = *obj
+	return nil
+}
+
+// nextID generate an id and set it to obj
+func (s *MemoryDB[T, I]) nextID(obj *T) (I, error) {
+	var id I
+	f := fieldOf(reflect.ValueOf(obj), s.idField)
+	if !f.IsValid() || !f.CanSet() {
+		return id, fmt.Errorf("scachetest: id field %s of %T can't be set", s.idField, *obj)
+	}
+	for {
+		s.seq++
+		if _, exists := s.rows[idOf[I](s.seq)]; !exists {
+			break
+		}
+	}
+	id = idOf[I](s.seq)
+	f.Set(reflect.ValueOf(id).Convert(f.Type()))
+	return id, nil
+}
+
+// idOf id of sequence n
+func idOf[I scache.IDType](n int64) I {
+	var id I
+	v := reflect.ValueOf(&id).Elem()
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(strconv.FormatInt(n, 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(n))
+	default:
+		v.SetInt(n)
+	}
+	return id
+}
+
+func (s *MemoryDB[T, I]) Save(obj *T) error {
+	return s.SaveCtx(context.Background(), obj)
+}
+
+func (s *MemoryDB[T, I]) SaveCtx(ctx context.Context, obj *T) error {
+	if scache.IsNullID((*obj).GetID()) {
+		return s.CreateCtx(ctx, obj)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rows[(*obj).GetID()] = *obj
+	return nil
+}
+
+func (s *MemoryDB[T, I]) Delete(ids ...I) (int64, error) {
+	return s.DeleteCtx(context.Background(), ids...)
+}
+
+func (s *MemoryDB[T, I]) DeleteCtx(ctx context.Context, ids ...I) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for _, id := range ids {
+		if _, ok := s.rows[id]; ok {
+			delete(s.rows, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *MemoryDB[T, I]) Update(id I, values interface{}) (int64, error) {
+	return s.UpdateCtx(context.Background(), id, values)
+}
+
+// UpdateCtx values can be a struct, whose non-zero fields are updated like gorm, or map[string]interface{} by field or column names
+func (s *MemoryDB[T, I]) UpdateCtx(ctx context.Context, id I, values interface{}) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.rows[id]
+	if !ok {
+		return 0, scache.ErrRecordNotFound
+	}
+	dst := reflect.ValueOf(&obj).Elem()
+	if vs, ok := values.(map[string]interface{}); ok {
+		if len(vs) == 0 {
+			return 0, nil
+		}
+		for k, v := range vs {
+			if err := setField(dst, k, reflect.ValueOf(v)); err != nil {
+				return 0, err
+			}
+		}
+	} else {
+		src := reflect.ValueOf(values)
+		for src.Kind() == reflect.Ptr {
+			src = src.Elem()
+		}
+		if src.Kind() != reflect.Struct {
+			return 0, fmt.Errorf("%w: update values of type %T", scache.ErrNotSupported, values)
+		}
+		for i := 0; i < src.NumField(); i++ {
+			f := src.Type().Field(i)
+			if !f.IsExported() || f.Anonymous || src.Field(i).IsZero() {
+				continue
+			}
+			if err := setField(dst, f.Name, src.Field(i)); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if obj.GetID() != id {
+		return 0, fmt.Errorf("%w: update id", scache.ErrNotSupported)
+	}
+	s.rows[id] = obj
+	return 1, nil
+}
+
+// setField set field of dst by field or column name
+func setField(dst reflect.Value, name string, v reflect.Value) error {
+	f := fieldOf(dst, name)
+	if !f.IsValid() || !f.CanSet() {
+		return fmt.Errorf("scachetest: field %s of %s not found", name, dst.Type())
+	}
+	if !v.IsValid() {
+		f.Set(reflect.Zero(f.Type()))
+		return nil
+	}
+	if !v.Type().ConvertibleTo(f.Type()) {
+		return fmt.Errorf("scachetest: can't set %s of %s to %s", name, dst.Type(), v.Type())
+	}
+	f.Set(v.Convert(f.Type()))
+	return nil
+}
+
+// fieldOf field of v by field name, or by column name, eg. category_id of CategoryId
+func fieldOf(v reflect.Value, name string) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	if f := v.FieldByName(name); f.IsValid() {
+		return f
+	}
+	column := strings.ReplaceAll(name, "_", "")
+	return v.FieldByNameFunc(func(n string) bool {
+		return strings.EqualFold(n, column)
+	})
+}
+
+func (s *MemoryDB[T, I]) Get(id I) (T, error) {
+	return s.GetCtx(context.Background(), id)
+}
+
+func (s *MemoryDB[T, I]) GetCtx(ctx context.Context, id I) (T, error) {
+	s.read()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.rows[id]
+	if !ok {
+		return r, scache.ErrRecordNotFound
+	}
+	return r, nil
+}
+
+func (s *MemoryDB[T, I]) List(ids ...I) ([]T, error) {
+	return s.ListCtx(context.Background(), ids...)
+}
+
+// ListCtx records found in order of ids
+func (s *MemoryDB[T, I]) ListCtx(ctx context.Context, ids ...I) ([]T, error) {
+	s.read()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r := make([]T, 0, len(ids))
+	for _, id := range ids {
+		if v, ok := s.rows[id]; ok {
+			r = append(r, v)
+		}
+	}
+	return r, nil
+}
+
+func (s *MemoryDB[T, I]) GetBy(index scache.Index) (T, error) {
+	return s.GetByCtx(context.Background(), index)
+}
+
+func (s *MemoryDB[T, I]) GetByCtx(ctx context.Context, index scache.Index) (T, error) {
+	var r T
+	rs := s.filter(index, nil)
+	if len(rs) == 0 {
+		return r, scache.ErrRecordNotFound
+	}
+	return rs[0], nil
+}
+
+func (s *MemoryDB[T, I]) ListBy(index scache.Index, orderBys scache.OrderBys) ([]T, error) {
+	return s.ListByCtx(context.Background(), index, orderBys)
+}
+
+func (s *MemoryDB[T, I]) ListByCtx(ctx context.Context, index scache.Index, orderBys scache.OrderBys) ([]T, error) {
+	return s.filter(index, orderBys), nil
+}
+
+func (s *MemoryDB[T, I]) CountBy(index scache.Index) (int64, error) {
+	return s.CountByCtx(context.Background(), index)
+}
+
+func (s *MemoryDB[T, I]) CountByCtx(ctx context.Context, index scache.Index) (int64, error) {
+	return int64(len(s.filter(index, nil))), nil
+}
+
+func (s *MemoryDB[T, I]) ListByPage(index scache.Index, orderBys scache.OrderBys, offset, limit int) ([]T, error) {
+	return s.ListByPageCtx(context.Background(), index, orderBys, offset, limit)
+}
+
+func (s *MemoryDB[T, I]) ListByPageCtx(ctx context.Context, index scache.Index, orderBys scache.OrderBys, offset, limit int) ([]T, error) {
+	rs := s.filter(index, orderBys)
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 || offset >= len(rs) {
+		return nil, nil
+	}
+	rs = rs[offset:]
+	if limit < len(rs) {
+		rs = rs[:limit]
+	}
+	return rs, nil
+}
+
+func (s *MemoryDB[T, I]) ListByUniqueInts(field string, values []int64) ([]T, error) {
+	return s.ListByUniqueIntsCtx(context.Background(), field, values)
+}
+
+func (s *MemoryDB[T, I]) ListByUniqueIntsCtx(ctx context.Context, field string, values []int64) ([]T, error) {
+	var r []T
+	for _, v := range values {
+		r = append(r, s.filter(scache.NewIndex(field, v), nil)...)
+	}
+	return r, nil
+}
+
+func (s *MemoryDB[T, I]) ListByUniqueStrs(field string, values []string) ([]T, error) {
+	return s.ListByUniqueStrsCtx(context.Background(), field, values)
+}
+
+func (s *MemoryDB[T, I]) ListByUniqueStrsCtx(ctx context.Context, field string, values []string) ([]T, error) {
+	var r []T
+	for _, v := range values {
+		r = append(r, s.filter(scache.NewIndex(field, v), nil)...)
+	}
+	return r, nil
+}
+
+func (s *MemoryDB[T, I]) ListAll() ([]T, error) {
+	return s.ListAllCtx(context.Background())
+}
+
+func (s *MemoryDB[T, I]) ListAllCtx(ctx context.Context) ([]T, error) {
+	return s.filter(nil, nil), nil
+}
+
+// filter records matching index in order of orderBys, then id
+func (s *MemoryDB[T, I]) filter(index scache.Index, orderBys scache.OrderBys) []T {
+	s.read()
+	s.mu.RLock()
+	var r []T
+	for _, v := range s.rows {
+		if Match(v, index) {
+			r = append(r, v)
+		}
+	}
+	s.mu.RUnlock()
+	sort.SliceStable(r, func(i, j int) bool {
+		for _, o := range orderBys {
+			c := compare(fieldOf(reflect.ValueOf(r[i]), o.Field), fieldOf(reflect.ValueOf(r[j]), o.Field))
+			if c != 0 {
+				return (c < 0) == o.Asc
+			}
+		}
+		return r[i].GetID() < r[j].GetID()
+	})
+	return r
+}
+
+// Match whether the fields of obj equal index, values are compared by string form like cache keys
+func Match(obj interface{}, index scache.Index) bool {
+	v := reflect.ValueOf(obj)
+	for k, want := range index {
+		f := fieldOf(v, k)
+		if !f.IsValid() || scache.Stringify(f.Interface(), "null") != scache.Stringify(want, "null") {
+			return false
+		}
+	}
+	return true
+}
+
+// compare -1, 0 or 1, nil pointers first
+func compare(a, b reflect.Value) int {
+	for a.Kind() == reflect.Ptr {
+		switch {
+		case a.IsNil() && b.IsNil():
+			return 0
+		case a.IsNil():
+			return -1
+		case b.IsNil():
+			return 1
+		}
+		a, b = a.Elem(), b.Elem()
+	}
+	if !a.IsValid() || !b.IsValid() {
+		return 0
+	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp(a.Int() < b.Int(), a.Int() > b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmp(a.Uint() < b.Uint(), a.Uint() > b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmp(a.Float() < b.Float(), a.Float() > b.Float())
+	case reflect.String:
+		return strings.Compare(a.String(), b.String())
+	case reflect.Bool:
+		return cmp(!a.Bool() && b.Bool(), a.Bool() && !b.Bool())
+	}
+	if ta, ok := a.Interface().(time.Time); ok {
+		tb := b.Interface().(time.Time)
+		return cmp(ta.Before(tb), ta.After(tb))
+	}
+	return 0
+}
+
+func cmp(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,19 @@
+package scachetest
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// NewRedis in-process redis stand-in, lua scripts supported, closed with the test.
+// Use the returned server to fast forward ttls, eg. mr.FastForward(time.Minute)
+func NewRedis(t testing.TB) (*redis.Client, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	red := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		red.Close()
+	})
+	return red, mr
+}
@@ -0,0 +1,192 @@
+package scachetest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/scache"
+	"github.com/daqiancode/scache/scachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+type user struct {
+	Id    int
+	Group int
+	Name  string
+}
+
+func (s user) GetID() int { return s.Id }
+func (s user) ListIndexes() scache.Indexes {
+	return scache.Indexes{scache.NewIndex("Group", s.Group), scache.NewIndex("Name", s.Name)}
+}
+
+func suite(newDB func(t *testing.T) scache.DBCRUD[user, int]) scachetest.Suite[user, int] {
+	return scachetest.Suite[user, int]{
+		New: newDB,
+		Records: []user{
+			{Group: 1, Name: "tom"},
+			{Group: 1, Name: "jerry"},
+			{Group: 2, Name: "spike"},
+		},
+		Update: func(obj user) (interface{}, user) {
+			obj.Group, obj.Name = 2, "tyke"
+			return map[string]interface{}{"Group": 2, "Name": "tyke"}, obj
+		},
+		MissingID:  100,
+		OrderField: "Name",
+	}
+}
+
+func TestMemoryDB(t *testing.T) {
+	suite(func(t *testing.T) scache.DBCRUD[user, int] {
+		return scachetest.NewMemoryDB[user, int]("Id")
+	}).Run(t)
+}
+
+func TestMemoryDBListByPage(t *testing.T) {
+	db := scachetest.NewMemoryDB[user, int]("Id")
+	for _, name := range []string{"a", "b", "c"} {
+		assert.Nil(t, db.Create(&user{Group: 1, Name: name}))
+	}
+	group := scache.NewIndex("Group", 1)
+	orderBys := scache.NewOrderBys("Name", true)
+	for _, c := range []struct {
+		offset, limit int
+		names         []string
+	}{{-1, 2, []string{"a", "b"}}, {1, 5, []string{"b", "c"}}, {0, 0, []string{}}, {0, -1, []string{}}, {3, 1, []string{}}} {
+		rs, err := db.ListByPage(group, orderBys, c.offset, c.limit)
+		assert.Nil(t, err)
+		assert.Equal(t, c.names, names(rs), "offset %d limit %d", c.offset, c.limit)
+	}
+}
+
+func TestRedisCache(t *testing.T) {
+	suite(func(t *testing.T) scache.DBCRUD[user, int] {
+		red, _ := scachetest.NewRedis(t)
+		return scache.NewRedisCache[user, int]("test", "user", "Id", scachetest.NewMemoryDB[user, int]("Id"), red, time.Minute)
+	}).Run(t)
+}
+
+func TestRedisCacheWithLeaseAndLocalCache(t *testing.T) {
+	suite(func(t *testing.T) scache.DBCRUD[user, int] {
+		red, _ := scachetest.NewRedis(t)
+		ca := scache.NewRedisCache[user, int]("test", "user", "Id", scachetest.NewMemoryDB[user, int]("Id"), red, time.Minute)
+		ca.EnableLease(scache.LeaseOptions{TTL: time.Second, Wait: 10 * time.Millisecond, Retries: 3})
+		ca.SetLocalCache(scache.NewLRUCache(100, time.Minute))
+		return ca
+	}).Run(t)
+}
+
+func TestFullRedisCache(t *testing.T) {
+	suite(func(t *testing.T) scache.DBCRUD[user, int] {
+		red, _ := scachetest.NewRedis(t)
+		return scache.NewFullRedisCache[user, int]("test", "user", "Id", scachetest.NewMemoryDB[user, int]("Id"), red, time.Minute)
+	}).Run(t)
+}
+
+func TestMemoryStore(t *testing.T) {
+	suite(func(t *testing.T) scache.DBCRUD[user, int] {
+		ca := scache.NewRedisCacheWithStore[user, int]("test", "user", "Id", scachetest.NewMemoryDB[user, int]("Id"), scache.NewMemoryStore(), time.Minute)
+		// redis only features are skipped
+		ca.EnableLease(scache.LeaseOptions{TTL: time.Second, Wait: 10 * time.Millisecond, Retries: 3})
+		ca.EnableInvalidation()
+		ca.EnableGeneration(time.Minute)
+		return ca
+	}).Run(t)
+	suite(func(t *testing.T) scache.DBCRUD[user, int] {
+		return scache.NewFullRedisCacheWithStore[user, int]("test", "user", "Id", scachetest.NewMemoryDB[user, int]("Id"), scache.NewMemoryStore(), time.Minute)
+	}).Run(t)
+}
+
+func TestMemoryStoreFallbacks(t *testing.T) {
+	db := scachetest.NewMemoryDB[user, int]("Id")
+	ca := scache.NewRedisCacheWithStore[user, int]("test", "user", "Id", db, scache.NewMemoryStore(), time.Minute)
+	assert.Nil(t, ca.AddRangeIndex("Id"))
+	for _, v := range []user{{Group: 1, Name: "tom"}, {Group: 1, Name: "jerry"}, {Group: 1, Name: "spike"}} {
+		assert.Nil(t, ca.Create(&v))
+	}
+	page, err := ca.ListByPage(scache.NewIndex("Group", 1), scache.NewOrderBys("Name", true), 1, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"spike"}, names(page))
+	rs, err := ca.ListByRangeRev(scache.NewIndex("Group", 1), "Id", 2, nil, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"spike", "jerry"}, names(rs))
+	assert.Nil(t, ca.EnableRetryQueue(scache.RetryOptions{}))
+	assert.ErrorIs(t, ca.EnableRetryQueue(scache.RetryOptions{ListKey: "retry"}), scache.ErrNotSupported)
+
+	ca.EnableGeneration(time.Minute)
+	for i := 0; i < 2; i++ {
+		rs, err := ca.ListBy(scache.NewIndex("Group", 1), nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(rs))
+	}
+	reads := db.Reads()
+	assert.Nil(t, ca.InvalidateAll())
+	_, err = ca.ListBy(scache.NewIndex("Group", 1), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, reads+1, db.Reads(), "abandoned by InvalidateAll")
+}
+
+func TestRedisCacheScripts(t *testing.T) {
+	red, _ := scachetest.NewRedis(t)
+	db := scachetest.NewMemoryDB[user, int]("Id")
+	ca := scache.NewRedisCache[user, int]("test", "user", "Id", db, red, time.Minute)
+	ca.EnableLease(scache.LeaseOptions{TTL: time.Second, Wait: 10 * time.Millisecond, Retries: 3})
+	assert.Nil(t, ca.AddRangeIndex("Id"))
+	for _, name := range []string{"a", "b", "c", "d"} {
+		assert.Nil(t, ca.Create(&user{Group: 1, Name: name}))
+	}
+	group := scache.NewIndex("Group", 1)
+
+	// built in background on the first miss
+	rs, err := ca.ListByPage(group, scache.NewOrderBys("Name", false), 1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"c", "b"}, names(rs))
+	assert.Eventually(t, func() bool {
+		return red.Exists(context.Background(), ca.PageKey(group, scache.NewOrderBys("Name", false))).Val() == 1
+	}, time.Second, 10*time.Millisecond)
+	rs, next, err := ca.ListByCursor(group, scache.NewOrderBys("Name", false), "", 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"d", "c", "b"}, names(rs))
+	rs, next, err = ca.ListByCursor(group, scache.NewOrderBys("Name", false), next, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a"}, names(rs))
+	assert.Equal(t, "", next)
+
+	for i := 0; i < 2; i++ {
+		rs, err = ca.ListByRangeRev(group, "Id", 2, nil, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"d", "c"}, names(rs))
+		n, err := ca.CountBy(group)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(4), n)
+	}
+	reads := db.Reads()
+	rs, err = ca.ListByRange(group, "Id", nil, 2, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, names(rs))
+	assert.Equal(t, reads, db.Reads())
+
+	// writes update the range index and clear pages & counts
+	_, err = ca.Delete(4)
+	assert.Nil(t, err)
+	assert.Nil(t, ca.Create(&user{Group: 1, Name: "e"}))
+	rs, err = ca.ListByRangeRev(group, "Id", nil, nil, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"e", "c", "b", "a"}, names(rs))
+	n, err := ca.CountBy(group)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), n)
+	rs, err = ca.ListByPage(group, scache.NewOrderBys("Name", false), 0, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"e", "c"}, names(rs))
+}
+
+func names(rs []user) []string {
+	r := make([]string, len(rs))
+	for i, v := range rs {
+		r[i] = v.Name
+	}
+	return r
+}
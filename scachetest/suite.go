@@ -0,0 +1,232 @@
+package scachetest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/daqiancode/scache"
+)
+
+// Suite conformance tests of Get, List, GetBy, ListBy, CountBy and invalidation by Update & Delete,
+// for a scache.Cache or a scache.DBCRUD like a custom adapter:
+//
+//	scachetest.Suite[User, int]{New: newCache, Records: users, Update: rename}.Run(t)
+//
+// Every read is done twice, so that caches are checked on both misses and hits
+type Suite[T scache.Table[I], I scache.IDType] struct {
+	// New an empty store for every test, eg. a cache with its own db & redis
+	New func(t *testing.T) scache.DBCRUD[T, I]
+	// Records created by every test, null ids are generated by Create. Some records should share indexes
+	Records []T
+	// Update values changing indexed fields of obj for Update, and obj after the update
+	Update func(obj T) (values interface{}, updated T)
+	// MissingID id of no record
+	MissingID I
+	// OrderField optional field to check ListBy in both orders
+	OrderField string
+	// Equal compare records, reflect.DeepEqual by default
+	Equal func(a, b T) bool
+}
+
+// Run run the suite as subtests of t
+func (s Suite[T, I]) Run(t *testing.T) {
+	t.Run("Get", func(t *testing.T) {
+		db, rs := s.setup(t)
+		s.verify(t, db, rs, nil)
+		for i := 0; i < 2; i++ {
+			if _, err := db.Get(s.MissingID); !errors.Is(err, scache.ErrRecordNotFound) {
+				t.Errorf("Get(%v) of missing id: want ErrRecordNotFound, got %v", s.MissingID, err)
+			}
+		}
+	})
+	t.Run("List", func(t *testing.T) {
+		db, rs := s.setup(t)
+		ids := []I{s.MissingID}
+		for _, v := range rs {
+			ids = append(ids, v.GetID())
+		}
+		for i := 0; i < 2; i++ {
+			got, err := db.List(ids...)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			// caches keep empty records of missing ids, databases skip them
+			var found []T
+			for _, v := range got {
+				if !scache.IsNullID(v.GetID()) {
+					found = append(found, v)
+				}
+			}
+			s.sameRecords(t, "List", rs, found)
+		}
+	})
+	t.Run("GetBy", func(t *testing.T) {
+		db, rs := s.setup(t)
+		for _, index := range indexesOf(rs) {
+			for i := 0; i < 2; i++ {
+				r, err := db.GetBy(index)
+				if err != nil {
+					t.Errorf("GetBy(%v): %v", index, err)
+					continue
+				}
+				if !Match(r, index) {
+					t.Errorf("GetBy(%v) = %+v, which doesn't match the index", index, r)
+				}
+			}
+		}
+	})
+	t.Run("ListBy", func(t *testing.T) {
+		db, rs := s.setup(t)
+		s.verify(t, db, rs, nil)
+		if s.OrderField == "" {
+			return
+		}
+		for _, index := range indexesOf(rs) {
+			asc, err := db.ListBy(index, scache.NewOrderBys(s.OrderField, true))
+			if err != nil {
+				t.Fatalf("ListBy(%v) asc: %v", index, err)
+			}
+			desc, err := db.ListBy(index, scache.NewOrderBys(s.OrderField, false))
+			if err != nil {
+				t.Fatalf("ListBy(%v) desc: %v", index, err)
+			}
+			for i := 1; i < len(asc); i++ {
+				if compare(fieldOf(reflect.ValueOf(asc[i-1]), s.OrderField), fieldOf(reflect.ValueOf(asc[i]), s.OrderField)) > 0 {
+					t.Errorf("ListBy(%v) asc by %s is not in order: %+v", index, s.OrderField, asc)
+					break
+				}
+			}
+			for i := 1; i < len(desc); i++ {
+				if compare(fieldOf(reflect.ValueOf(desc[i-1]), s.OrderField), fieldOf(reflect.ValueOf(desc[i]), s.OrderField)) < 0 {
+					t.Errorf("ListBy(%v) desc by %s is not in order: %+v", index, s.OrderField, desc)
+					break
+				}
+			}
+		}
+	})
+	t.Run("Update", func(t *testing.T) {
+		if s.Update == nil {
+			t.Skip("no Update")
+		}
+		db, rs := s.setup(t)
+		// fill the cache before the update
+		s.verify(t, db, rs, nil)
+		old := rs[0]
+		values, updated := s.Update(old)
+		if _, err := db.Update(old.GetID(), values); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		rs[0] = updated
+		s.verify(t, db, rs, old.ListIndexes())
+	})
+	t.Run("Delete", func(t *testing.T) {
+		db, rs := s.setup(t)
+		s.verify(t, db, rs, nil)
+		old := rs[0]
+		if _, err := db.Delete(old.GetID()); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := db.Get(old.GetID()); !errors.Is(err, scache.ErrRecordNotFound) {
+				t.Errorf("Get(%v) after Delete: want ErrRecordNotFound, got %v", old.GetID(), err)
+			}
+		}
+		s.verify(t, db, rs[1:], old.ListIndexes())
+	})
+}
+
+// setup a new store with Records created
+func (s Suite[T, I]) setup(t *testing.T) (scache.DBCRUD[T, I], []T) {
+	if len(s.Records) == 0 {
+		t.Fatal("scachetest: no Records")
+	}
+	db := s.New(t)
+	rs := make([]T, len(s.Records))
+	for i, v := range s.Records {
+		if err := db.Create(&v); err != nil {
+			t.Fatalf("Create(%+v): %v", v, err)
+		}
+		rs[i] = v
+	}
+	return db, rs
+}
+
+// verify db holds rs: Get by ids, ListBy & CountBy by the indexes of rs and extra indexes
+func (s Suite[T, I]) verify(t *testing.T, db scache.DBCRUD[T, I], rs []T, extra scache.Indexes) {
+	t.Helper()
+	for _, want := range rs {
+		for i := 0; i < 2; i++ {
+			got, err := db.Get(want.GetID())
+			if err != nil {
+				t.Errorf("Get(%v): %v", want.GetID(), err)
+			} else if !s.equal(want, got) {
+				t.Errorf("Get(%v) = %+v, want %+v", want.GetID(), got, want)
+			}
+		}
+	}
+	for _, index := range append(indexesOf(rs), extra...) {
+		var want []T
+		for _, v := range rs {
+			if Match(v, index) {
+				want = append(want, v)
+			}
+		}
+		for i := 0; i < 2; i++ {
+			got, err := db.ListBy(index, nil)
+			if err != nil {
+				t.Errorf("ListBy(%v): %v", index, err)
+				continue
+			}
+			s.sameRecords(t, "ListBy", want, got)
+			n, err := db.CountBy(index)
+			if err != nil {
+				t.Errorf("CountBy(%v): %v", index, err)
+			} else if n != int64(len(want)) {
+				t.Errorf("CountBy(%v) = %d, want %d", index, n, len(want))
+			}
+		}
+	}
+}
+
+// sameRecords got has the records of want in any order
+func (s Suite[T, I]) sameRecords(t *testing.T, op string, want, got []T) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s = %d records %+v, want %d records %+v", op, len(got), got, len(want), want)
+		return
+	}
+	byId := make(map[I]T, len(got))
+	for _, v := range got {
+		byId[v.GetID()] = v
+	}
+	for _, v := range want {
+		if g, ok := byId[v.GetID()]; !ok || !s.equal(v, g) {
+			t.Errorf("%s = %+v, want %+v", op, got, want)
+			return
+		}
+	}
+}
+
+func (s Suite[T, I]) equal(a, b T) bool {
+	if s.Equal != nil {
+		return s.Equal(a, b)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// indexesOf distinct indexes of rs
+func indexesOf[T scache.Table[I], I scache.IDType](rs []T) scache.Indexes {
+	seen := make(map[string]bool)
+	var r scache.Indexes
+	for _, v := range rs {
+		for _, index := range v.ListIndexes() {
+			key := (&scache.CacheBase[T, I]{}).MakeCacheKey(index)
+			if !seen[key] {
+				seen[key] = true
+				r = append(r, index)
+			}
+		}
+	}
+	return r
+}
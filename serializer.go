@@ -0,0 +1,41 @@
+package scache
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// nullValue marks a record missing in database, it is written as is and never passed to a Serializer
+const nullValue = "null"
+
+// MsgpackSerializer encode objects with msgpack, smaller and faster than json for large structs
+type MsgpackSerializer struct {
+}
+
+func (s *MsgpackSerializer) Marshal(obj interface{}) (string, error) {
+	b, err := msgpack.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+func (s *MsgpackSerializer) Unmarshal(data string, objRef interface{}) error {
+	return msgpack.Unmarshal([]byte(data), objRef)
+}
+
+// GobSerializer encode objects with encoding/gob, only exported fields are encoded
+type GobSerializer struct {
+}
+
+func (s *GobSerializer) Marshal(obj interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(obj); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+func (s *GobSerializer) Unmarshal(data string, objRef interface{}) error {
+	return gob.NewDecoder(bytes.NewBufferString(data)).Decode(objRef)
+}
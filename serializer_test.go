@@ -0,0 +1,100 @@
+package scache_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/scache"
+	"github.com/daqiancode/scache/scachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+type doc struct {
+	Id   string
+	Body string
+	Tags []string
+}
+
+func TestSerializers(t *testing.T) {
+	d := doc{Id: "1", Body: strings.Repeat("scache ", 1000), Tags: []string{"a", "b"}}
+	serializers := map[string]scache.Serializer{
+		"json":        &scache.JsonSerializer{},
+		"msgpack":     &scache.MsgpackSerializer{},
+		"gob":         &scache.GobSerializer{},
+		"snappy+json": scache.NewSnappySerializer(&scache.JsonSerializer{}, 0),
+		"zstd+gob":    scache.NewZstdSerializer(&scache.GobSerializer{}, 0),
+	}
+	for name, s := range serializers {
+		data, err := s.Marshal(d)
+		assert.Nil(t, err, name)
+		var r doc
+		assert.Nil(t, s.Unmarshal(data, &r), name)
+		assert.Equal(t, d, r, name)
+	}
+}
+
+func TestCompressSerializer(t *testing.T) {
+	inner := &scache.JsonSerializer{}
+	s := scache.NewZstdSerializer(inner, 100)
+	small := doc{Id: "1"}
+	large := doc{Id: "2", Body: strings.Repeat("scache ", 1000)}
+
+	plain, _ := inner.Marshal(large)
+	data, err := s.Marshal(large)
+	assert.Nil(t, err)
+	assert.Less(t, len(data), len(plain))
+
+	// below threshold: header byte + plain payload
+	plainSmall, _ := inner.Marshal(small)
+	data, err = s.Marshal(small)
+	assert.Nil(t, err)
+	assert.Equal(t, len(plainSmall)+1, len(data))
+
+	// written before compression was enabled
+	var r doc
+	assert.Nil(t, s.Unmarshal(plain, &r))
+	assert.Equal(t, large, r)
+
+	// written by another codec
+	data, _ = scache.NewSnappySerializer(inner, 100).Marshal(large)
+	r = doc{}
+	assert.Nil(t, s.Unmarshal(data, &r))
+	assert.Equal(t, large, r)
+}
+
+func TestCacheSerializers(t *testing.T) {
+	serializers := map[string]scache.Serializer{
+		"msgpack": &scache.MsgpackSerializer{},
+		"gob":     &scache.GobSerializer{},
+		"zstd":    scache.NewZstdSerializer(&scache.MsgpackSerializer{}, 0),
+	}
+	for name, serializer := range serializers {
+		t.Run(name, func(t *testing.T) {
+			s := scachetest.Suite[member, int]{
+				Records:   []member{{Group: 1}, {Group: 1}, {Group: 2}},
+				MissingID: 100,
+			}
+			s.New = func(t *testing.T) scache.DBCRUD[member, int] {
+				red, _ := scachetest.NewRedis(t)
+				return scache.NewRedisCache[member, int]("test", "member", "Id", scachetest.NewMemoryDB[member, int]("Id"), red, time.Minute, scache.WithSerializer(serializer))
+			}
+			s.Run(t)
+			s.New = func(t *testing.T) scache.DBCRUD[member, int] {
+				red, _ := scachetest.NewRedis(t)
+				return scache.NewFullRedisCache[member, int]("test", "member", "Id", scachetest.NewMemoryDB[member, int]("Id"), red, time.Minute, scache.WithSerializer(serializer))
+			}
+			s.Run(t)
+
+			red, mr := scachetest.NewRedis(t)
+			ca := scache.NewRedisCache[member, int]("test", "member", "Id", scachetest.NewMemoryDB[member, int]("Id"), red, time.Minute, scache.WithSerializer(serializer))
+			assert.Equal(t, serializer, ca.GetSerializer())
+			assert.Nil(t, ca.Create(&member{Id: 1, Group: 1}))
+			_, err := ca.Get(1)
+			assert.Nil(t, err)
+			raw, err := mr.Get(ca.MakeCacheKey(scache.NewIndex("Id", 1)))
+			assert.Nil(t, err)
+			assert.False(t, strings.HasPrefix(raw, "{"), "not json")
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package scache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store storage of cache entries, RedisStore keeps them in redis, MemoryStore in a map of the process.
+// Missing keys and fields are reported by redis.Nil
+type Store interface {
+	// Get value of key
+	Get(ctx context.Context, key string) (string, error)
+	// MGet values of keys, nil for missing keys, strings otherwise
+	MGet(ctx context.Context, keys ...string) ([]interface{}, error)
+	// Set key with ttl
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// MSet keys with their own ttls
+	MSet(ctx context.Context, entries ...Entry) error
+	Del(ctx context.Context, keys ...string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	// Expire set ttls of keys, missing keys are skipped
+	Expire(ctx context.Context, ttls map[string]time.Duration) error
+	// Incr increase the integer at key by 1, missing key is 0
+	Incr(ctx context.Context, key string) (int64, error)
+	HGet(ctx context.Context, key, field string) (string, error)
+	// HMGet values of fields, nil for missing fields, strings otherwise
+	HMGet(ctx context.Context, key string, fields ...string) ([]interface{}, error)
+	HGetAll(ctx context.Context, key string) (map[string]string, error)
+	HSet(ctx context.Context, key string, values map[string]string) error
+	HDel(ctx context.Context, key string, fields ...string) error
+	// SAdd add members to the set at key, and set its ttl
+	SAdd(ctx context.Context, key string, ttl time.Duration, members ...string) error
+	SMembers(ctx context.Context, key string) ([]string, error)
+	Ping(ctx context.Context) error
+}
+
+// Entry a value with its ttl
+type Entry struct {
+	Key   string
+	Value string
+	TTL   time.Duration
+}
+
+// RedisBackend optional interface of stores backed by redis.
+// Leases, pagination, range indexes, invalidation broadcast and the redis list of the retry queue need lua scripts, lists, sorted sets or pub/sub,
+// with other stores they are skipped or fall back to db
+type RedisBackend interface {
+	Redis() *redis.Client
+}
+
+// redisClient client of a store backed by redis, nil for other stores
+func redisClient(store Store) *redis.Client {
+	if r, ok := store.(RedisBackend); ok {
+		return r.Redis()
+	}
+	return nil
+}
+
+// RedisStore store in redis
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Redis() *redis.Client {
+	return s.client
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (string, error) {
+	return s.client.Get(ctx, key).Result()
+}
+
+func (s *RedisStore) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	return s.client.MGet(ctx, keys...).Result()
+}
+
+func (s *RedisStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return s.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (s *RedisStore) MSet(ctx context.Context, entries ...Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	p := s.client.Pipeline()
+	for _, v := range entries {
+		p.Set(ctx, v.Key, v.Value, v.TTL)
+	}
+	_, err := p.Exec(ctx)
+	return err
+}
+
+func (s *RedisStore) Del(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return s.client.Del(ctx, keys...).Err()
+}
+
+func (s *RedisStore) Exists(ctx context.Context, key string) (bool, error) {
+	n, err := s.client.Exists(ctx, key).Result()
+	return n > 0, err
+}
+
+func (s *RedisStore) Expire(ctx context.Context, ttls map[string]time.Duration) error {
+	if len(ttls) == 0 {
+		return nil
+	}
+	p := s.client.Pipeline()
+	for k, v := range ttls {
+		p.PExpire(ctx, k, v)
+	}
+	_, err := p.Exec(ctx)
+	return err
+}
+
+func (s *RedisStore) Incr(ctx context.Context, key string) (int64, error) {
+	return s.client.Incr(ctx, key).Result()
+}
+
+func (s *RedisStore) HGet(ctx context.Context, key, field string) (string, error) {
+	return s.client.HGet(ctx, key, field).Result()
+}
+
+func (s *RedisStore) HMGet(ctx context.Context, key string, fields ...string) ([]interface{}, error) {
+	return s.client.HMGet(ctx, key, fields...).Result()
+}
+
+func (s *RedisStore) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return s.client.HGetAll(ctx, key).Result()
+}
+
+func (s *RedisStore) HSet(ctx context.Context, key string, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	args := make([]string, 0, len(values)*2)
+	for k, v := range values {
+		args = append(args, k, v)
+	}
+	return s.client.HSet(ctx, key, args).Err()
+}
+
+func (s *RedisStore) HDel(ctx context.Context, key string, fields ...string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return s.client.HDel(ctx, key, fields...).Err()
+}
+
+func (s *RedisStore) SAdd(ctx context.Context, key string, ttl time.Duration, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(members))
+	for i, v := range members {
+		args[i] = v
+	}
+	p := s.client.Pipeline()
+	p.SAdd(ctx, key, args...)
+	p.PExpire(ctx, key, ttl)
+	_, err := p.Exec(ctx)
+	return err
+}
+
+func (s *RedisStore) SMembers(ctx context.Context, key string) ([]string, error) {
+	return s.client.SMembers(ctx, key).Result()
+}
+
+func (s *RedisStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
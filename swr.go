@@ -0,0 +1,104 @@
+package scache
+
+import (
+	"context"
+	"encoding/binary"
+	"sort"
+	"strings"
+	"time"
+)
+
+// headerSoftExpiry starts entries written with a soft expiry: header + 8 bytes big endian unix milliseconds + payload.
+// It can't start a json, gob or compressed payload, nor any msgpack value scache writes
+const headerSoftExpiry byte = 0xC7
+
+func wrapSoftExpiry(payload string, softExpireAt time.Time) string {
+	b := make([]byte, 9, 9+len(payload))
+	b[0] = headerSoftExpiry
+	binary.BigEndian.PutUint64(b[1:], uint64(softExpireAt.UnixMilli()))
+	return string(append(b, payload...))
+}
+
+// unwrapSoftExpiry return (payload, soft expiry in unix milliseconds, whether data has a soft expiry)
+func unwrapSoftExpiry(data string) (string, int64, bool) {
+	if len(data) < 9 || data[0] != headerSoftExpiry {
+		return data, 0, false
+	}
+	return data[9:], int64(binary.BigEndian.Uint64([]byte(data[1:9]))), true
+}
+
+// SetSoftTTL entries written afterwards are stale after softTTL but still served until ttl, 0 disables it.
+// Entries are decoded whether soft ttl is enabled or not
+func (s *RedisJson[T]) SetSoftTTL(softTTL time.Duration) {
+	s.softTTL = softTTL
+}
+func (s *RedisJson[T]) GetSoftTTL() time.Duration {
+	return s.softTTL
+}
+
+// encode add soft expiry to payload
+func (s *RedisJson[T]) encode(payload string) string {
+	if s.softTTL <= 0 {
+		return payload
+	}
+	return wrapSoftExpiry(payload, time.Now().Add(s.softTTL))
+}
+
+// decode unmarshal data into r, return whether it is stale or null
+func (s *RedisJson[T]) decode(data string, r *T) (hit, error) {
+	payload, softExpireAt, ok := unwrapSoftExpiry(data)
+	h := hit{stale: ok && time.Now().UnixMilli() > softExpireAt}
+	if payload == nullValue {
+		h.null = true
+		return h, nil
+	}
+	return h, decodeError(s.serializer.Unmarshal(payload, r))
+}
+
+// EnableStaleWhileRevalidate entries older than softTTL are still returned at once, and refreshed from db in background,
+// at most one refresh per key at a time. softTTL should be shorter than ttl, which removes entries from redis
+func (s *RedisCache[T, I]) EnableStaleWhileRevalidate(softTTL time.Duration) {
+	s.red.SetSoftTTL(softTTL)
+	s.redId.SetSoftTTL(softTTL)
+	s.redIds.SetSoftTTL(softTTL)
+}
+
+// revalidate refresh key from db in background under the lease of key, the refresh is skipped while another reader holds the lease
+func (s *RedisCache[T, I]) revalidate(key string, load func(ctx context.Context, l *lease) error) {
+	s.refresh(key, func(ctx context.Context) error {
+		l, err := s.refreshLease(ctx, key)
+		if err != nil {
+			return err
+		}
+		if l != nil && l.token == "" {
+			return nil
+		}
+		return load(ctx, l)
+	})
+}
+
+// refresh run load in background, concurrent refreshes of the same key are coalesced
+func (s *RedisCache[T, I]) refresh(key string, load func(ctx context.Context) error) {
+	s.sf.DoChan("revalidate:"+key, func() (interface{}, error) {
+		// the request context may be done before the refresh
+		err := load(context.Background())
+		if err != nil && err != ErrRecordNotFound {
+			s.warn("scache: revalidate failed", err, "key", key)
+		}
+		return nil, err
+	})
+}
+
+// revalidateList refresh stale records of ids in background
+func (s *RedisCache[T, I]) revalidateList(ids []I) {
+	keys := make([]string, len(ids))
+	for i, v := range ids {
+		keys[i] = s.MakeCacheKey(NewIndex(s.GetIdField(), v))
+	}
+	sort.Strings(keys)
+	// batch fills are not leased, like the misses of List
+	s.refresh("list:"+strings.Join(keys, ","), func(ctx context.Context) error {
+		_, err := s.loadList(ctx, ids)
+		return err
+	})
+}
@@ -0,0 +1,101 @@
+package scache_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/scache"
+	"github.com/daqiancode/scache/scachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSoftExpiryRoundTrip(t *testing.T) {
+	red, mr := scachetest.NewRedis(t)
+	d := doc{Id: "1", Body: strings.Repeat("scache ", 1000), Tags: []string{"a"}}
+	serializers := map[string]scache.Serializer{
+		"json":           &scache.JsonSerializer{},
+		"msgpack":        &scache.MsgpackSerializer{},
+		"snappy+msgpack": scache.NewSnappySerializer(&scache.MsgpackSerializer{}, 0),
+		"zstd+json":      scache.NewZstdSerializer(&scache.JsonSerializer{}, 0),
+	}
+	for name, serializer := range serializers {
+		plain := scache.NewRedisJson[doc](red, time.Minute)
+		plain.SetSerializer(serializer)
+		soft := scache.NewRedisJson[doc](red, time.Minute)
+		soft.SetSerializer(serializer)
+		soft.SetSoftTTL(time.Second)
+
+		// written with soft expiry, read with and without it
+		assert.Nil(t, soft.SetJson(name, d), name)
+		raw, _ := mr.Get(name)
+		assert.Equal(t, byte(0xC7), raw[0], name)
+		for _, r := range []*scache.RedisJson[doc]{soft, plain} {
+			v, err := r.GetJson(name)
+			assert.Nil(t, err, name)
+			assert.Equal(t, d, v, name)
+		}
+
+		// written before stale-while-revalidate was enabled
+		assert.Nil(t, plain.SetJson(name+"/legacy", d), name)
+		v, err := soft.GetJson(name + "/legacy")
+		assert.Nil(t, err, name)
+		assert.Equal(t, d, v, name)
+
+		assert.Nil(t, soft.SetNull(name+"/null"), name)
+		for _, r := range []*scache.RedisJson[doc]{soft, plain} {
+			v, err := r.GetJson(name + "/null")
+			assert.Nil(t, err, name)
+			assert.Equal(t, doc{}, v, name)
+		}
+	}
+}
+
+// slowDB counts record loads, which take delay
+type slowDB struct {
+	*scachetest.MemoryDB[member, int]
+	delay time.Duration
+	calls int64
+}
+
+func (s *slowDB) GetCtx(ctx context.Context, id int) (member, error) {
+	atomic.AddInt64(&s.calls, 1)
+	time.Sleep(s.delay)
+	return s.MemoryDB.GetCtx(ctx, id)
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	red, _ := scachetest.NewRedis(t)
+	db := &slowDB{MemoryDB: scachetest.NewMemoryDB[member, int]("Id"), delay: 50 * time.Millisecond}
+	assert.Nil(t, db.Create(&member{Id: 1, Group: 1}))
+	ca := scache.NewRedisCache[member, int]("test", "member", "Id", db, red, time.Minute)
+	ca.EnableStaleWhileRevalidate(100 * time.Millisecond)
+	_, err := ca.Get(1)
+	assert.Nil(t, err)
+	_, err = db.Update(1, map[string]interface{}{"Group": 2})
+	assert.Nil(t, err)
+	time.Sleep(110 * time.Millisecond)
+
+	// stale entries are served at once, and refreshed once
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			r, err := ca.Get(1)
+			assert.Nil(t, err)
+			assert.Equal(t, member{Id: 1, Group: 1}, r)
+			assert.Less(t, time.Since(start), db.delay)
+		}()
+	}
+	wg.Wait()
+	time.Sleep(2 * db.delay)
+	r, err := ca.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, member{Id: 1, Group: 2}, r)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&db.calls))
+}
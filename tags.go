@@ -0,0 +1,254 @@
+package scache
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// TableMeta id & indexes of a table declared by scache struct tags:
+//
+//	type Order struct {
+//		ID     uint   `scache:"id"`
+//		Uid    uint   `scache:"index,index:uid_status"`
+//		Status string `scache:"index:uid_status"`
+//		No     string `scache:"unique"`
+//	}
+//
+// `index` indexes the field alone, `index:name` fields with the same name form one composite index, `unique` is a single field unique index.
+// Without an id tag, the field named ID or Id is the id
+type TableMeta struct {
+	// IdField name of the id field
+	IdField string
+	// Indexes field names of every index, in order of declaration
+	Indexes [][]string
+	// Uniques fields of unique indexes
+	Uniques []string
+	// Tagged whether any field has a scache tag
+	Tagged bool
+	// idPath field index path of the id field
+	idPath []int
+	// paths field index paths by field name
+	paths map[string][]int
+}
+
+// TagOptions options of a scache struct tag
+type TagOptions struct {
+	// ID `id`
+	ID bool
+	// Index `index`
+	Index bool
+	// IndexNames names of `index:name`
+	IndexNames []string
+	// Unique `unique`
+	Unique bool
+}
+
+// ParseTag parse the value of a scache struct tag, eg. `index,index:uid_status`. Unknown options are ignored
+func ParseTag(tag string) TagOptions {
+	var r TagOptions
+	for _, item := range strings.Split(tag, ",") {
+		item = strings.TrimSpace(item)
+		switch {
+		case item == "id":
+			r.ID = true
+		case item == "index":
+			r.Index = true
+		case strings.HasPrefix(item, "index:"):
+			r.IndexNames = append(r.IndexNames, strings.TrimPrefix(item, "index:"))
+		case item == "unique":
+			r.Unique = true
+		}
+	}
+	return r
+}
+
+var tableMetas sync.Map
+
+// MetaOf parse scache tags of T, the result is cached per type
+func MetaOf[T any]() *TableMeta {
+	return metaOfType(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+func metaOfType(t reflect.Type) *TableMeta {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if m, ok := tableMetas.Load(t); ok {
+		return m.(*TableMeta)
+	}
+	m := parseTableMeta(t)
+	r, _ := tableMetas.LoadOrStore(t, m)
+	return r.(*TableMeta)
+}
+
+func parseTableMeta(t reflect.Type) *TableMeta {
+	m := &TableMeta{paths: make(map[string][]int)}
+	if t.Kind() != reflect.Struct {
+		return m
+	}
+	// fields in order of declaration, promoted ones included
+	var fields []reflect.StructField
+	var walk func(t reflect.Type, path []int)
+	walk = func(t reflect.Type, path []int) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			f.Index = append(path[:len(path):len(path)], i)
+			if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("scache") == "" {
+				// eg. gorm.Model
+				walk(f.Type, f.Index)
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if _, ok := f.Tag.Lookup("scache"); ok {
+				m.Tagged = true
+			}
+			fields = append(fields, f)
+		}
+	}
+	walk(t, nil)
+	// as go promotes fields, the shallowest field of a name wins, names ambiguous at that depth are dropped
+	ambiguous := make(map[string]bool)
+	for _, f := range fields {
+		p, ok := m.paths[f.Name]
+		switch {
+		case !ok || len(f.Index) < len(p):
+			m.paths[f.Name] = f.Index
+			ambiguous[f.Name] = false
+		case len(f.Index) == len(p):
+			ambiguous[f.Name] = true
+		}
+	}
+	for name, v := range ambiguous {
+		if v {
+			delete(m.paths, name)
+		}
+	}
+	named := make(map[string]int)
+	for _, f := range fields {
+		if p, ok := m.paths[f.Name]; !ok || len(p) != len(f.Index) {
+			// hidden by a shallower or ambiguous field
+			continue
+		}
+		tag, ok := f.Tag.Lookup("scache")
+		if !ok {
+			continue
+		}
+		opts := ParseTag(tag)
+		if opts.ID {
+			m.IdField = f.Name
+		}
+		if opts.Index {
+			m.Indexes = append(m.Indexes, []string{f.Name})
+		}
+		for _, name := range opts.IndexNames {
+			if j, ok := named[name]; ok {
+				m.Indexes[j] = append(m.Indexes[j], f.Name)
+			} else {
+				named[name] = len(m.Indexes)
+				m.Indexes = append(m.Indexes, []string{f.Name})
+			}
+		}
+		if opts.Unique {
+			m.Uniques = append(m.Uniques, f.Name)
+		}
+	}
+	if m.IdField == "" {
+		for _, name := range []string{"ID", "Id"} {
+			if _, ok := m.paths[name]; ok {
+				m.IdField = name
+				break
+			}
+		}
+	}
+	m.idPath = m.paths[m.IdField]
+	return m
+}
+
+// IsUnique whether field is declared unique
+func (s *TableMeta) IsUnique(field string) bool {
+	for _, v := range s.Uniques {
+		if v == field {
+			return true
+		}
+	}
+	return false
+}
+
+// TagID id of obj by scache tags, for implementing Table.GetID:
+//
+//	func (s Order) GetID() uint { return scache.TagID[uint](s) }
+func TagID[I IDType](obj interface{}) I {
+	var r I
+	v := reflect.ValueOf(obj)
+	m := metaOfType(v.Type())
+	if m.idPath == nil {
+		return r
+	}
+	f, ok := fieldByPath(v, m.idPath)
+	if !ok {
+		return r
+	}
+	t := reflect.TypeOf(r)
+	if f.Type().ConvertibleTo(t) {
+		r = f.Convert(t).Interface().(I)
+	}
+	return r
+}
+
+// TagIndexes indexes of obj by scache tags, unique indexes included, for implementing Table.ListIndexes:
+//
+//	func (s Order) ListIndexes() scache.Indexes { return scache.TagIndexes(s) }
+func TagIndexes(obj interface{}) Indexes {
+	v := reflect.ValueOf(obj)
+	m := metaOfType(v.Type())
+	r := make(Indexes, 0, len(m.Indexes)+len(m.Uniques))
+	for _, fields := range m.Indexes {
+		index := make(Index, len(fields))
+		for _, field := range fields {
+			if f, ok := fieldByPath(v, m.paths[field]); ok {
+				index[field] = f.Interface()
+			}
+		}
+		r = append(r, index)
+	}
+	for _, field := range m.Uniques {
+		if f, ok := fieldByPath(v, m.paths[field]); ok {
+			r = append(r, NewIndex(field, f.Interface()))
+		}
+	}
+	return r
+}
+
+// fieldByPath field of v, ok=false if v is a nil pointer
+func fieldByPath(v reflect.Value, path []int) (reflect.Value, bool) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	for _, i := range path {
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+// checkUnique field must be declared unique if T has scache tags, a field with duplicates would be cached with one id only
+func checkUnique[T any](field string) error {
+	if m := MetaOf[T](); m.Tagged && !m.IsUnique(field) {
+		return fmt.Errorf("%w: %s is not declared unique by scache tags", ErrNotSupported, field)
+	}
+	return nil
+}
+
+// idFieldOf idField, or the id field declared by scache tags of T if it is empty
+func idFieldOf[T any](idField string) string {
+	if idField != "" {
+		return idField
+	}
+	return MetaOf[T]().IdField
+}
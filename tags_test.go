@@ -0,0 +1,76 @@
+package scache_test
+
+import (
+	"testing"
+
+	"github.com/daqiancode/scache"
+	"github.com/stretchr/testify/assert"
+)
+
+type tagBase struct {
+	ID uint
+}
+
+type tagOrder struct {
+	tagBase
+	Uid    uint   `scache:"index,index:uid_status"`
+	Status string `scache:"index:uid_status"`
+	No     string `scache:"unique"`
+	Name   string
+}
+
+func (s tagOrder) GetID() uint                 { return scache.TagID[uint](s) }
+func (s tagOrder) ListIndexes() scache.Indexes { return scache.TagIndexes(s) }
+
+func TestTags(t *testing.T) {
+	m := scache.MetaOf[tagOrder]()
+	assert.Equal(t, "ID", m.IdField)
+	assert.Equal(t, [][]string{{"Uid"}, {"Uid", "Status"}}, m.Indexes)
+	assert.Equal(t, []string{"No"}, m.Uniques)
+	assert.True(t, m.Tagged)
+	assert.True(t, m.IsUnique("No"))
+	assert.False(t, m.IsUnique("Uid"))
+
+	o := tagOrder{tagBase: tagBase{ID: 3}, Uid: 1, Status: "paid", No: "A1"}
+	assert.Equal(t, uint(3), o.GetID())
+	assert.Equal(t, scache.Indexes{
+		scache.NewIndex("Uid", uint(1)),
+		scache.NewIndex("Uid", uint(1)).Add("Status", "paid"),
+		scache.NewIndex("No", "A1"),
+	}, o.ListIndexes())
+
+	ca := scache.NewRedisCache[tagOrder, uint]("test", "order", "", nil, nil, 0)
+	assert.Equal(t, "ID", ca.GetIdField())
+}
+
+type tagShadowed struct {
+	tagBase
+	ID   string `scache:"id"`
+	Code string `scache:"unique"`
+	tagCode
+	tagName
+	tagLabel
+}
+
+type tagCode struct {
+	Code string `scache:"index"`
+}
+
+type tagName struct {
+	Name string `scache:"index"`
+}
+
+type tagLabel struct {
+	Name string
+}
+
+func TestTagsPromotion(t *testing.T) {
+	m := scache.MetaOf[tagShadowed]()
+	// the outer ID and Code hide the embedded ones, Name is ambiguous
+	assert.Equal(t, "ID", m.IdField)
+	assert.Equal(t, []string{"Code"}, m.Uniques)
+	assert.Empty(t, m.Indexes)
+	o := tagShadowed{tagBase: tagBase{ID: 3}, ID: "a", Code: "x", tagCode: tagCode{Code: "y"}}
+	assert.Equal(t, "a", scache.TagID[string](o))
+	assert.Equal(t, scache.Indexes{scache.NewIndex("Code", "x")}, scache.TagIndexes(o))
+}